@@ -0,0 +1,97 @@
+package alert
+
+import (
+	"context"
+	"math"
+	"nuist_rover/configuration"
+	"nuist_rover/logger"
+	"nuist_rover/nuistnet/model"
+	"sync"
+	"time"
+)
+
+// Monitor warns once each time the balance of an interface's account drops below the threshold
+type Monitor struct {
+	Threshold model.Money
+	Notifiers []Notifier
+	mutex     sync.Mutex
+	below     map[holder]bool
+}
+
+// holder is an account signed in on an interface, so that after a failover
+// the new account's balance is tracked apart from the old one's
+type holder struct {
+	nic      string
+	username string
+}
+
+// NewMonitor returns nil if no threshold is configured
+func NewMonitor(config configuration.Alerts) *Monitor {
+	if config.Balance <= 0 {
+		return nil
+	}
+
+	var notifiers []Notifier
+	if len(config.Command) > 0 {
+		notifiers = append(notifiers, CommandNotifier{Command: config.Command})
+	}
+	if len(config.Webhook) > 0 {
+		notifiers = append(notifiers, WebhookNotifier{Url: config.Webhook})
+	}
+	return &Monitor{
+		Threshold: model.Money(math.Round(config.Balance * 100)),
+		Notifiers: notifiers,
+		below:     make(map[holder]bool),
+	}
+}
+
+// Reload applies config to m, keeping which accounts are already below the threshold
+// unless the threshold itself changed, so a reload doesn't warn about them again
+func (m *Monitor) Reload(config configuration.Alerts) *Monitor {
+	reloaded := NewMonitor(config)
+	if m == nil || reloaded == nil {
+		return reloaded
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if reloaded.Threshold != m.Threshold {
+		m.below = reloaded.below
+	}
+	m.Threshold = reloaded.Threshold
+	m.Notifiers = reloaded.Notifiers
+	return m
+}
+
+func (m *Monitor) Observe(ctx context.Context, nic string, username string, balance model.Money, log logger.Logger) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	threshold, notifiers := m.Threshold, m.Notifiers
+	key := holder{nic: nic, username: username}
+	below := balance < threshold
+	crossed := below && !m.below[key]
+	m.below[key] = below
+	m.mutex.Unlock()
+	if !crossed {
+		return
+	}
+
+	log.Warning("balance of %s on %s is %s, below %s", username, nic, balance, threshold)
+	event := Event{
+		Nic:       nic,
+		Username:  username,
+		Balance:   balance,
+		Threshold: threshold,
+	}
+	for _, notifier := range notifiers {
+		notifyCtx, cancelNotifyCtx := context.WithTimeout(ctx, 30*time.Second)
+		err := notifier.Notify(notifyCtx, event)
+		cancelNotifyCtx()
+		if err != nil {
+			log.Warning("failed to notify low balance on %s: %s", nic, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"nuist_rover/nuistnet/model"
+	"os"
+	"os/exec"
+)
+
+type Event struct {
+	Nic       string      `json:"nic"`
+	Username  string      `json:"username"`
+	Balance   model.Money `json:"balance"`
+	Threshold model.Money `json:"threshold"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// CommandNotifier runs a shell command with the event passed as NUISTROVER_* environment variables
+type CommandNotifier struct {
+	Command string
+}
+
+func (n CommandNotifier) Notify(ctx context.Context, event Event) error {
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", n.Command)
+	cmd.Env = append(os.Environ(),
+		"NUISTROVER_NIC="+event.Nic,
+		"NUISTROVER_USERNAME="+event.Username,
+		"NUISTROVER_BALANCE="+event.Balance.String(),
+		"NUISTROVER_THRESHOLD="+event.Threshold.String(),
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("alert command failed: %s (output: %s)", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// WebhookNotifier posts the event as JSON to an url
+type WebhookNotifier struct {
+	Url string
+}
+
+func (n WebhookNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, "POST", n.Url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("alert webhook failed: %s", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook responded with %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"nuist_rover/configuration"
+	"nuist_rover/control"
+	"nuist_rover/nuistnet"
+	"nuist_rover/nuistnet/trace"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type loginCmd struct {
+	Nic    string `arg:"" optional:"" help:"Name of the interface to sign in on, defaults to all."`
+	Retry  bool   `help:"Retry at least once if signin fails."`
+	Direct bool   `help:"Dial by this process even if a daemon is running."`
+}
+
+func (c *loginCmd) Run(g *globals) error {
+	config, log, err := g.load()
+	if err != nil {
+		return err
+	}
+	accounts, err := selectAccounts(*config, c.Nic)
+	if err != nil {
+		return err
+	}
+
+	if !c.Direct && len(config.Control.Socket) > 0 {
+		client := control.NewClient(config.Control.Socket)
+		pingCtx, cancelPingCtx := context.WithTimeout(context.Background(), time.Second)
+		running := client.Ping(pingCtx)
+		cancelPingCtx()
+		if running {
+			log.Log("delegating to the running daemon")
+			var problems []error
+			for _, nic := range slices.Sorted(maps.Keys(accounts)) {
+				if err := client.Dial(context.Background(), nic); err != nil {
+					problems = append(problems, fmt.Errorf("%s: %s", nic, err))
+				}
+			}
+			return errors.Join(problems...)
+		}
+	}
+	if config.Retry > 0 || c.Retry {
+		config.Retry = max(config.Retry, 1)
+	}
+
+	runInterruptible(log, func(ctx context.Context) {
+		dialer{config: *config, log: log}.dial_all_parallel(ctx, accounts)
+	})
+	return nil
+}
+
+type statusCmd struct {
+	Nic    string `arg:"" optional:"" help:"Name of the interface to query, defaults to all."`
+	Format string `enum:"table,json" default:"table" help:"Output format, one of table or json."`
+}
+
+func (c *statusCmd) Run(g *globals) error {
+	config, log, err := g.load()
+	if err != nil {
+		return err
+	}
+	accounts, err := selectAccounts(*config, c.Nic)
+	if err != nil {
+		return err
+	}
+
+	var reports []stateReport
+	runInterruptible(log, func(ctx context.Context) {
+		reports = query_all_parallel(ctx, accounts, *config)
+	})
+
+	if c.Format == "json" {
+		return printStateJson(os.Stdout, reports)
+	}
+	return printStateTable(os.Stdout, reports)
+}
+
+type logoutCmd struct {
+	Nic string `arg:"" optional:"" help:"Name of the interface to sign off, defaults to all."`
+}
+
+func (c *logoutCmd) Run(g *globals) error {
+	config, log, err := g.load()
+	if err != nil {
+		return err
+	}
+	accounts, err := selectAccounts(*config, c.Nic)
+	if err != nil {
+		return err
+	}
+
+	runInterruptible(log, func(ctx context.Context) {
+		logout_all_parallel(ctx, accounts, *config, log)
+	})
+	return nil
+}
+
+type replayCmd struct {
+	File string `arg:"" help:"Trace file recorded with --trace." type:"existingfile"`
+}
+
+func (c *replayCmd) Run(g *globals) error {
+	file, err := os.Open(c.File)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return trace.Read(file, func(record trace.Record) error {
+		fmt.Printf("[%s] %s via %s: ", record.Time.Format("2006-01-02 15:04:05"), record.Endpoint, record.LocalAddr)
+		decoded, err := nuistnet.Replay(record)
+		if err != nil {
+			fmt.Printf("error: %s\n", err)
+		} else {
+			fmt.Printf("%+v\n", decoded)
+		}
+		return nil
+	})
+}
+
+type configCheckCmd struct{}
+
+func (c *configCheckCmd) Run(g *globals) error {
+	config, _, err := g.load()
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	fmt.Printf("%s is valid\n", g.Configuration)
+	return nil
+}
+
+type controlCmd struct {
+	State  controlStateCmd  `cmd:"" help:"Show what the daemon knows about each interface."`
+	Dial   controlDialCmd   `cmd:"" help:"Make the daemon dial an interface immediately."`
+	Pause  controlPauseCmd  `cmd:"" help:"Stop the daemon from dialing an interface."`
+	Resume controlResumeCmd `cmd:"" help:"Let the daemon dial a paused interface again."`
+	Reload controlReloadCmd `cmd:"" help:"Make the daemon reload the configuration file."`
+}
+
+func (g *globals) controlClient() (control.Client, error) {
+	config, _, err := g.load()
+	if err != nil {
+		return control.Client{}, err
+	}
+	if len(config.Control.Socket) <= 0 {
+		return control.Client{}, errors.New("control socket is not configured")
+	}
+	return control.NewClient(config.Control.Socket), nil
+}
+
+type controlStateCmd struct{}
+
+func (c *controlStateCmd) Run(g *globals) error {
+	client, err := g.controlClient()
+	if err != nil {
+		return err
+	}
+	states, err := client.States(context.Background())
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "NIC\tUSER\tSTATE\tFAMILIES\tLAST DIAL\tLAST ERROR")
+	for _, state := range states {
+		status := "offline"
+		if state.Paused {
+			status = "paused"
+		} else if state.Online {
+			status = "online"
+		}
+		lastDial := "never"
+		if !state.LastDial.IsZero() {
+			lastDial = state.LastDial.Format("2006-01-02 15:04:05")
+		}
+		families := make([]string, 0, len(state.Families))
+		for _, family := range slices.Sorted(maps.Keys(state.Families)) {
+			if state.Families[family] {
+				families = append(families, family+":online")
+			} else {
+				families = append(families, family+":offline")
+			}
+		}
+		if len(families) <= 0 {
+			families = append(families, "-")
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", state.Nic, state.Username, status, strings.Join(families, " "), lastDial, state.LastError)
+	}
+	return table.Flush()
+}
+
+type controlDialCmd struct {
+	Nic string `arg:"" help:"Name of the interface to dial."`
+}
+
+func (c *controlDialCmd) Run(g *globals) error {
+	client, err := g.controlClient()
+	if err != nil {
+		return err
+	}
+	return client.Dial(context.Background(), c.Nic)
+}
+
+type controlPauseCmd struct {
+	Nic string `arg:"" help:"Name of the interface to pause."`
+}
+
+func (c *controlPauseCmd) Run(g *globals) error {
+	client, err := g.controlClient()
+	if err != nil {
+		return err
+	}
+	return client.Pause(context.Background(), c.Nic)
+}
+
+type controlResumeCmd struct {
+	Nic string `arg:"" help:"Name of the interface to resume."`
+}
+
+func (c *controlResumeCmd) Run(g *globals) error {
+	client, err := g.controlClient()
+	if err != nil {
+		return err
+	}
+	return client.Resume(context.Background(), c.Nic)
+}
+
+type controlReloadCmd struct{}
+
+func (c *controlReloadCmd) Run(g *globals) error {
+	client, err := g.controlClient()
+	if err != nil {
+		return err
+	}
+	return client.Reload(context.Background())
+}
+
+type configEncryptPasswordCmd struct {
+	KeyFile string `required:"" help:"Key file the password is encrypted with, same as [secrets] keyfile." type:"existingfile"`
+}
+
+func (c *configEncryptPasswordCmd) Run(g *globals) error {
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if len(password) <= 0 {
+		return errors.New("empty password")
+	}
+
+	encrypted, err := configuration.EncryptPassword(c.KeyFile, password)
+	if err != nil {
+		return err
+	}
+	fmt.Println(encrypted)
+	return nil
+}
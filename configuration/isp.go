@@ -0,0 +1,34 @@
+package configuration
+
+import (
+	"fmt"
+	"nuist_rover/nuistnet/isp"
+)
+
+func (i *isps) UnmarshalTOML(data any) error {
+	switch value := data.(type) {
+	case string:
+		*i = isps{value}
+	case []any:
+		list := make(isps, len(value))
+		for index, item := range value {
+			name, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("isp must be a string, got %v", item)
+			}
+			list[index] = name
+		}
+		*i = list
+	default:
+		return fmt.Errorf("isp must be a string or a list of strings, got %v", data)
+	}
+	return nil
+}
+
+func (i isps) parse() []isp.Type {
+	types := make([]isp.Type, len(i))
+	for index, name := range i {
+		types[index] = isp.Parse(name)
+	}
+	return types
+}
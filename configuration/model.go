@@ -1,42 +1,148 @@
 package configuration
 
 import (
+	"github.com/BurntSushi/toml"
 	"nuist_rover/nuistnet/model"
+	"nuist_rover/retry"
+	"slices"
 	"time"
 )
 
 type account struct {
-	Username string
-	Password string
-	Isp      string
+	Username          string
+	Password          string
+	PasswordFile      string `toml:"password_file"`
+	PasswordEnv       string `toml:"password_env"`
+	PasswordCommand   string `toml:"password_command"`
+	PasswordEncrypted string `toml:"password_encrypted"`
+	Isp               isps
+	TestInterval      string
 }
 
+// isps is either a single isp or a list of them in order of preference
+type isps []string
+
 type OnlineCheck struct {
 	Enabled   bool
 	Method    string
 	Host      string
 	Count     int
 	Threshold float64
+	// Mode combines the results of Checks, one of any (default), all or quorum
+	Mode string
+	// Quorum is how many checks must pass in quorum mode, defaults to a majority
+	Quorum int
+	Checks []Check
+}
+
+// Check configures one online check. Fields that do not apply to its method are ignored
+type Check struct {
+	Method    string
+	Host      string
+	Count     int
+	Threshold float64
+	// Url is fetched by the http method
+	Url string
+	// Expect is a piece of the body the http method expects, or a 204 response if empty
+	Expect string
+	// Server is the name server the dns method asks, defaults to the system one
+	Server string
+	// Family is the address family the icmp and http methods check, ipv4 (default) or ipv6
+	Family string
+	// Nics restricts the check to some interfaces, defaults to all
+	Nics []string
+}
+
+type Alerts struct {
+	Balance float64
+	Command string
+	Webhook string
+}
+
+type Metrics struct {
+	Listen string
+}
+
+type Control struct {
+	Socket string
+}
+
+type Secrets struct {
+	KeyFile string
+}
+
+type Log struct {
+	Format string
+	File   string
+	// MaxSize is in kilobytes
+	MaxSize int
+	Backups int
+	// Syslog is "local" for /dev/log, or udp://host:port or tcp://host:port
+	Syslog string
 }
 
 type root struct {
-	ServerUrl             string
-	Retry                 uint
-	RetryInterval         string
-	TestInterval          string
-	Verbose               string
-	RestartLink           bool
-	OnlineCheck           OnlineCheck
-	Accounts              map[string]account
+	ServerUrl     string
+	Retry         uint
+	RetryInterval string
+	RetryPolicy   string
+	RetryMax      string
+	RetryJitter   float64
+	TestInterval  string
+	Verbose       string
+	RestartLink   bool
+	IPv6          bool
+	OnlineCheck   OnlineCheck
+	Alerts        Alerts
+	Metrics       Metrics
+	Control       Control
+	Secrets       Secrets
+	Log           Log
+	// Accounts is either a table or an array of tables per interface
+	Accounts map[string]toml.Primitive
+	accounts map[string][]account
 }
 
 type Root struct {
-	ServerUrl             string
-	Retry                 uint
-	RetryInterval         time.Duration
-	TestInterval          time.Duration
-	Verbose               string
-	RestartLink           bool
-	OnlineCheck           OnlineCheck
-	Accounts              map[string]model.Account
+	ServerUrl   string
+	Retry       uint
+	RetryPolicy retry.Policy
+	// RetryPolicyName is the policy as configured, kept for Validate since unknown names parse as fixed
+	RetryPolicyName string
+	TestInterval    time.Duration
+	Verbose         string
+	RestartLink     bool
+	IPv6            bool
+	OnlineCheck     OnlineCheck
+	Alerts          Alerts
+	Metrics         Metrics
+	Control         Control
+	Log             Log
+	// Accounts lists the accounts of each interface in order of preference
+	Accounts map[string][]model.Account
+	// TestIntervals overrides TestInterval for some interfaces
+	TestIntervals map[string]time.Duration
+}
+
+// TestIntervalOf returns how often the daemon checks the interface
+func (r Root) TestIntervalOf(nic string) time.Duration {
+	if interval, ok := r.TestIntervals[nic]; ok {
+		return interval
+	}
+	return r.TestInterval
+}
+
+// ChecksOf returns the online checks that apply to the interface. Without any
+// [[onlinecheck.checks]], the method configured in [onlinecheck] itself is the only one
+func (o OnlineCheck) ChecksOf(nic string) []Check {
+	if len(o.Checks) <= 0 {
+		return []Check{{Method: o.Method, Host: o.Host, Count: o.Count, Threshold: o.Threshold}}
+	}
+	var checks []Check
+	for _, check := range o.Checks {
+		if len(check.Nics) <= 0 || slices.Contains(check.Nics, nic) {
+			checks = append(checks, check)
+		}
+	}
+	return checks
 }
@@ -3,19 +3,32 @@ package configuration
 import (
 	"fmt"
 	"github.com/BurntSushi/toml"
-	"nuist_rover/nuistnet/isp"
 	"nuist_rover/nuistnet/model"
+	"nuist_rover/retry"
 	"strings"
 	"time"
 )
 
-func (r root) toRoot() Root {
-	accounts := make(map[string]model.Account)
-	for nic, acc := range r.Accounts {
-		accounts[nic] = model.Account{
-			Username: acc.Username,
-			Password: acc.Password,
-			Isp:      isp.Parse(acc.Isp),
+func (r root) toRoot() (Root, error) {
+	accounts := make(map[string][]model.Account)
+	testIntervals := make(map[string]time.Duration)
+	for nic, list := range r.accounts {
+		for _, acc := range list {
+			password, err := acc.resolvePassword(r.Secrets.KeyFile)
+			if err != nil {
+				return Root{}, fmt.Errorf("error reading password of account %s on %s: %s", acc.Username, nic, err)
+			}
+			accounts[nic] = append(accounts[nic], model.Account{
+				Username: acc.Username,
+				Password: password,
+				Isp:      acc.Isp.parse(),
+			})
+			if _, ok := testIntervals[nic]; ok {
+				continue
+			}
+			if interval, err := time.ParseDuration(acc.TestInterval); err == nil && interval > 0 {
+				testIntervals[nic] = interval
+			}
 		}
 	}
 	testInterval, err := time.ParseDuration(r.TestInterval)
@@ -26,29 +39,67 @@ func (r root) toRoot() Root {
 	if err != nil {
 		retryInterval = 0
 	}
+	retryMax, err := time.ParseDuration(r.RetryMax)
+	if err != nil {
+		retryMax = 0
+	}
 	serverUrl := r.ServerUrl
 	if !strings.HasPrefix(serverUrl, "http://") && !strings.HasPrefix(serverUrl, "https://") {
 		serverUrl = "http://" + serverUrl
 	}
 	return Root{
-		ServerUrl:     serverUrl,
-		Retry:         r.Retry,
-		RetryInterval: retryInterval,
-		TestInterval:  testInterval,
-		Verbose:       r.Verbose,
-		RestartLink:   r.RestartLink,
-		OnlineCheck:   r.OnlineCheck,
-		Accounts:      accounts,
-	}
+		ServerUrl: serverUrl,
+		Retry:     r.Retry,
+		RetryPolicy: retry.Policy{
+			Kind:     retry.ParseKind(r.RetryPolicy),
+			Interval: retryInterval,
+			Max:      retryMax,
+			Jitter:   r.RetryJitter,
+		},
+		RetryPolicyName: r.RetryPolicy,
+		TestInterval:    testInterval,
+		Verbose:         r.Verbose,
+		RestartLink:     r.RestartLink,
+		IPv6:            r.IPv6,
+		OnlineCheck:     r.OnlineCheck,
+		Alerts:          r.Alerts,
+		Metrics:         r.Metrics,
+		Control:         r.Control,
+		Log:             r.Log,
+		Accounts:        accounts,
+		TestIntervals:   testIntervals,
+	}, nil
 }
 
 func Parse(filename string) (*Root, error) {
 	var config root
-	_, err := toml.DecodeFile(filename, &config)
+	metadata, err := toml.DecodeFile(filename, &config)
 	if err != nil {
 		return nil, fmt.Errorf("error reading configuration file: %s", err)
 	}
 
-	rootConfig := config.toRoot()
+	config.accounts = make(map[string][]account, len(config.Accounts))
+	for nic, primitive := range config.Accounts {
+		var list []account
+		switch metadata.Type("accounts", nic) {
+		case "ArrayHash", "Array":
+			err = metadata.PrimitiveDecode(primitive, &list)
+		default:
+			list = make([]account, 1)
+			err = metadata.PrimitiveDecode(primitive, &list[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading accounts on %s: %s", nic, err)
+		}
+		if len(list) <= 0 {
+			return nil, fmt.Errorf("no account is configured for %s", nic)
+		}
+		config.accounts[nic] = list
+	}
+
+	rootConfig, err := config.toRoot()
+	if err != nil {
+		return nil, err
+	}
 	return &rootConfig, nil
 }
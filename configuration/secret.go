@@ -0,0 +1,122 @@
+package configuration
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const passwordCommandTimeout = 10 * time.Second
+
+// resolvePassword reads the password from whichever single source the account specifies
+func (acc account) resolvePassword(keyFile string) (string, error) {
+	sources := 0
+	for _, source := range []string{acc.Password, acc.PasswordFile, acc.PasswordEnv, acc.PasswordCommand, acc.PasswordEncrypted} {
+		if len(source) > 0 {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return "", errors.New("more than one password source is given")
+	}
+
+	switch {
+	case len(acc.PasswordFile) > 0:
+		content, err := os.ReadFile(acc.PasswordFile)
+		if err != nil {
+			return "", fmt.Errorf("cannot read password file: %s", err)
+		}
+		return firstLine(content), nil
+	case len(acc.PasswordEnv) > 0:
+		password, ok := os.LookupEnv(acc.PasswordEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", acc.PasswordEnv)
+		}
+		return password, nil
+	case len(acc.PasswordCommand) > 0:
+		ctx, cancelCtx := context.WithTimeout(context.Background(), passwordCommandTimeout)
+		defer cancelCtx()
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", acc.PasswordCommand)
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("password command failed: %s (stderr: %s)", err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return firstLine(output), nil
+	case len(acc.PasswordEncrypted) > 0:
+		if len(keyFile) <= 0 {
+			return "", errors.New("encrypted password is given without [secrets] keyfile")
+		}
+		return DecryptPassword(keyFile, acc.PasswordEncrypted)
+	default:
+		return acc.Password, nil
+	}
+}
+
+// firstLine is the text up to the first line break, since password managers
+// like pass print metadata on the lines following the password
+func firstLine(content []byte) string {
+	line, _, _ := strings.Cut(string(content), "\n")
+	return strings.TrimSuffix(line, "\r")
+}
+
+func readKey(keyFile string) (cipher.AEAD, error) {
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read key file: %s", err)
+	}
+	if len(bytes.TrimSpace(content)) <= 0 {
+		return nil, errors.New("key file is empty")
+	}
+	key := sha256.Sum256(content)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptPassword seals a password with AES-256-GCM under the SHA-256 of the key file,
+// returning base64 suitable for password_encrypted
+func EncryptPassword(keyFile string, password string) (string, error) {
+	aead, err := readKey(keyFile)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(password), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func DecryptPassword(keyFile string, encrypted string) (string, error) {
+	aead, err := readKey(keyFile)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("encrypted password is not base64: %s", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("encrypted password is too short")
+	}
+	password, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("cannot decrypt password, is the key file right?")
+	}
+	return string(password), nil
+}
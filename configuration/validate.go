@@ -0,0 +1,100 @@
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"nuist_rover/nuistnet/isp"
+	"slices"
+)
+
+var onlineCheckMethods = []string{"", "portal", "ping", "icmp", "http", "captive", "tcp", "dns"}
+
+var addressFamilies = []string{"", "ipv4", "ipv6"}
+
+var onlineCheckModes = []string{"", "any", "all", "quorum"}
+
+var retryPolicies = []string{"", "fixed", "linear", "exponential"}
+
+var logFormats = []string{"", "text", "logfmt", "json"}
+
+// Validate reports every problem found in the configuration, joined as one error
+func (r Root) Validate() error {
+	var problems []error
+
+	serverUrl, err := url.Parse(r.ServerUrl)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("invalid server url %s: %s", r.ServerUrl, err))
+	} else if len(serverUrl.Host) <= 0 {
+		problems = append(problems, errors.New("server url has empty value"))
+	}
+
+	if !slices.Contains(retryPolicies, r.RetryPolicyName) {
+		problems = append(problems, fmt.Errorf("unknown retry policy: %s", r.RetryPolicyName))
+	}
+	if r.RetryPolicy.Jitter < 0 || r.RetryPolicy.Jitter > 1 {
+		problems = append(problems, fmt.Errorf("retry jitter %g is out of range [0, 1]", r.RetryPolicy.Jitter))
+	}
+
+	if r.OnlineCheck.Enabled {
+		problems = append(problems, r.OnlineCheck.validate(r.IPv6)...)
+	}
+
+	if !slices.Contains(logFormats, r.Log.Format) {
+		problems = append(problems, fmt.Errorf("unknown log format: %s", r.Log.Format))
+	}
+	if len(r.Log.Syslog) > 0 && r.Log.Syslog != "local" {
+		syslogUrl, err := url.Parse(r.Log.Syslog)
+		if err != nil || (syslogUrl.Scheme != "udp" && syslogUrl.Scheme != "tcp") || len(syslogUrl.Host) <= 0 {
+			problems = append(problems, fmt.Errorf("invalid syslog address %s, expecting local, udp://host:port or tcp://host:port", r.Log.Syslog))
+		}
+	}
+
+	if len(r.Accounts) <= 0 {
+		problems = append(problems, errors.New("no account is configured"))
+	}
+	for nic, accounts := range r.Accounts {
+		for i, account := range accounts {
+			if len(account.Username) <= 0 {
+				problems = append(problems, fmt.Errorf("account #%d on %s has empty username", i+1, nic))
+			}
+			if len(account.Password) <= 0 {
+				problems = append(problems, fmt.Errorf("account #%d on %s has empty password", i+1, nic))
+			}
+			if len(account.Isp) <= 0 {
+				problems = append(problems, fmt.Errorf("account #%d on %s has no isp", i+1, nic))
+			}
+			if slices.Contains(account.Isp, isp.UNKNOWN) {
+				problems = append(problems, fmt.Errorf("account #%d on %s has unknown isp", i+1, nic))
+			}
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// validate takes whether IPv6 is enabled, as the client has no IPv6 address to check from otherwise
+func (o OnlineCheck) validate(ipv6 bool) []error {
+	var problems []error
+	if !slices.Contains(onlineCheckMethods, o.Method) {
+		problems = append(problems, fmt.Errorf("unknown online check method: %s", o.Method))
+	}
+	for i, check := range o.Checks {
+		if !slices.Contains(onlineCheckMethods, check.Method) {
+			problems = append(problems, fmt.Errorf("online check #%d has unknown method: %s", i+1, check.Method))
+		}
+		if !slices.Contains(addressFamilies, check.Family) {
+			problems = append(problems, fmt.Errorf("online check #%d has unknown family: %s", i+1, check.Family))
+		}
+		if check.Family == "ipv6" && !ipv6 {
+			problems = append(problems, fmt.Errorf("online check #%d is over ipv6 while ipv6 is not enabled", i+1))
+		}
+	}
+	if !slices.Contains(onlineCheckModes, o.Mode) {
+		problems = append(problems, fmt.Errorf("unknown online check mode: %s", o.Mode))
+	}
+	if o.Mode == "quorum" && (o.Quorum < 0 || o.Quorum > max(len(o.Checks), 1)) {
+		problems = append(problems, fmt.Errorf("online check quorum %d is out of range", o.Quorum))
+	}
+	return problems
+}
@@ -0,0 +1,77 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// Client talks to a running daemon over its control socket
+type Client struct {
+	http http.Client
+}
+
+func NewClient(socket string) Client {
+	dialer := net.Dialer{}
+	return Client{http: http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}}}
+}
+
+// Ping reports whether a daemon is listening on the socket
+func (c Client) Ping(ctx context.Context) bool {
+	_, err := c.States(ctx)
+	return err == nil
+}
+
+func (c Client) States(ctx context.Context) ([]NicState, error) {
+	var states []NicState
+	err := c.request(ctx, "GET", "/v1/state", &states)
+	return states, err
+}
+
+func (c Client) Dial(ctx context.Context, nic string) error {
+	return c.request(ctx, "POST", "/v1/dial/"+url.PathEscape(nic), nil)
+}
+
+func (c Client) Pause(ctx context.Context, nic string) error {
+	return c.request(ctx, "POST", "/v1/pause/"+url.PathEscape(nic), nil)
+}
+
+func (c Client) Resume(ctx context.Context, nic string) error {
+	return c.request(ctx, "POST", "/v1/resume/"+url.PathEscape(nic), nil)
+}
+
+func (c Client) Reload(ctx context.Context) error {
+	return c.request(ctx, "POST", "/v1/reload", nil)
+}
+
+func (c Client) request(ctx context.Context, method string, path string, result any) error {
+	request, err := http.NewRequestWithContext(ctx, method, "http://daemon"+path, nil)
+	if err != nil {
+		return err
+	}
+	response, err := c.http.Do(request)
+	if err != nil {
+		return fmt.Errorf("could not connect to daemon: %s", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var body errorResponse
+		if err := json.NewDecoder(response.Body).Decode(&body); err != nil || len(body.Error) <= 0 {
+			return fmt.Errorf("daemon responded with %s", response.Status)
+		}
+		return errors.New(body.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
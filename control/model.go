@@ -0,0 +1,31 @@
+package control
+
+import (
+	"context"
+	"time"
+)
+
+// NicState is what the daemon knows about one interface
+type NicState struct {
+	Nic      string `json:"nic"`
+	Username string `json:"username"`
+	Paused   bool   `json:"paused"`
+	Online   bool   `json:"online"`
+	// Families tells whether each address family, "ipv4" or "ipv6", is online
+	Families  map[string]bool `json:"families,omitempty"`
+	LastDial  time.Time       `json:"last_dial,omitzero"`
+	LastError string          `json:"last_error,omitempty"`
+}
+
+// Daemon is the set of operations exposed over the control socket
+type Daemon interface {
+	Dial(ctx context.Context, nic string) error
+	States() []NicState
+	Pause(nic string) error
+	Resume(nic string) error
+	Reload() error
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
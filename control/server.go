@@ -0,0 +1,70 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"nuist_rover/logger"
+	"os"
+)
+
+// Serve listens on a Unix socket and answers control requests until ctx is done
+func Serve(ctx context.Context, socket string, daemon Daemon, log logger.Logger) error {
+	if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		_ = listener.Close()
+		return err
+	}
+
+	server := http.Server{Handler: newHandler(daemon)}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	log.Info("serving control api on %s", socket)
+	err = server.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func newHandler(daemon Daemon) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/state", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, daemon.States(), nil)
+	})
+	mux.HandleFunc("POST /v1/dial/{nic}", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, nil, daemon.Dial(r.Context(), r.PathValue("nic")))
+	})
+	mux.HandleFunc("POST /v1/pause/{nic}", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, nil, daemon.Pause(r.PathValue("nic")))
+	})
+	mux.HandleFunc("POST /v1/resume/{nic}", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, nil, daemon.Resume(r.PathValue("nic")))
+	})
+	mux.HandleFunc("POST /v1/reload", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, nil, daemon.Reload())
+	})
+	return mux
+}
+
+func respond(w http.ResponseWriter, body any, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		body = errorResponse{Error: err.Error()}
+	} else if body == nil {
+		body = struct{}{}
+	}
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"nuist_rover/alert"
+	"nuist_rover/configuration"
+	"nuist_rover/control"
+	"nuist_rover/logger"
+	"nuist_rover/metrics"
+	"nuist_rover/nuistnet/model"
+	"nuist_rover/retry"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type daemonCmd struct {
+	Retry bool `help:"Retry at least once if signin fails."`
+}
+
+func (c *daemonCmd) Run(g *globals) error {
+	d := &daemon{
+		globals:     g,
+		retry:       c.Retry,
+		states:      make(map[string]control.NicState),
+		supervisors: make(map[string]*supervisor),
+		active:      make(map[string]int),
+	}
+	config, log, err := d.loadConfig()
+	if err != nil {
+		return err
+	}
+	if len(config.Metrics.Listen) > 0 {
+		d.metrics = metrics.New()
+	}
+
+	runInterruptible(log, func(ctx context.Context) {
+		d.run(ctx, *config, log)
+	})
+	return nil
+}
+
+// daemon keeps every configured interface online and serves the control api
+type daemon struct {
+	globals *globals
+	retry   bool
+	metrics *metrics.Metrics
+
+	mutex       sync.Mutex
+	ctx         context.Context
+	config      configuration.Root
+	log         logger.Logger
+	monitor     *alert.Monitor
+	states      map[string]control.NicState
+	supervisors map[string]*supervisor
+	// active is the index of the account holding the link of each interface
+	active map[string]int
+	// tracking is set once address and link updates are subscribed to
+	tracking bool
+}
+
+// loadConfig parses and validates the configuration file, so that the daemon starts with
+// no configuration a reload would refuse
+func (d *daemon) loadConfig() (*configuration.Root, logger.Logger, error) {
+	config, log, err := d.globals.load()
+	if err != nil {
+		return nil, log, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, log, err
+	}
+	if config.Retry > 0 || d.retry {
+		config.Retry = max(config.Retry, 1)
+	}
+	if config.TestInterval <= 0 {
+		config.TestInterval = 1 * time.Minute
+		log.Info("running in daemon mode while test interval has empty value, defaulting to %s", config.TestInterval.String())
+	}
+	return config, log, nil
+}
+
+// apply replaces the running configuration. Interfaces whose account was removed or changed have their
+// supervisor stopped, cancelling dials in flight, and added or changed ones get a new supervisor that
+// dials immediately. It returns the number of interfaces added and removed, where a change counts as both
+func (d *daemon) apply(config configuration.Root, log logger.Logger) (added int, removed int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for nic, accounts := range d.config.Accounts {
+		if updated, ok := config.Accounts[nic]; !ok || !slices.EqualFunc(updated, accounts, model.Account.Equal) {
+			removed++
+			d.supervisors[nic].cancel()
+			delete(d.supervisors, nic)
+			delete(d.active, nic)
+			if !ok {
+				delete(d.states, nic)
+			}
+		}
+	}
+	for nic, accounts := range config.Accounts {
+		state := d.states[nic]
+		state.Nic = nic
+		state.Username = accounts[d.active[nic]].Username
+		d.states[nic] = state
+
+		if current, ok := d.config.Accounts[nic]; !ok || !slices.EqualFunc(current, accounts, model.Account.Equal) {
+			added++
+			s := newSupervisor(d.ctx, nic)
+			d.supervisors[nic] = s
+			go s.run(d)
+		} else if config.TestIntervalOf(nic) != d.config.TestIntervalOf(nic) {
+			d.supervisors[nic].reschedule()
+		}
+	}
+
+	d.config = config
+	d.log = log
+	d.monitor = d.monitor.Reload(config.Alerts)
+	return
+}
+
+func (d *daemon) dialer() dialer {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return dialer{config: d.config, log: d.log, monitor: d.monitor, metrics: d.metrics}
+}
+
+func (d *daemon) paused(nic string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.states[nic].Paused
+}
+
+// nextWait is how long the supervisor of nic waits before dialing again.
+// After failures it starts over at the retry interval and backs off exponentially up to the test interval
+func (d *daemon) nextWait(nic string, failures int) time.Duration {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	interval := d.config.TestIntervalOf(nic)
+	if failures <= 0 {
+		return interval
+	}
+	backoff := retry.Policy{
+		Kind:     retry.EXPONENTIAL,
+		Interval: d.config.RetryPolicy.Interval,
+		Max:      interval,
+		Jitter:   d.config.RetryPolicy.Jitter,
+	}
+	return backoff.Delay(failures)
+}
+
+func (d *daemon) run(ctx context.Context, config configuration.Root, log logger.Logger) {
+	d.ctx = ctx
+	d.apply(config, log)
+
+	if d.metrics != nil {
+		go serveMetrics(ctx, config.Metrics.Listen, d.metrics, log)
+	}
+	go d.trackLinks(ctx, log)
+	if len(config.Control.Socket) > 0 {
+		go func() {
+			if err := control.Serve(ctx, config.Control.Socket, d, log); err != nil {
+				log.Warning("control api stopped: %s", err)
+			}
+		}()
+	}
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	for {
+		select {
+		case <-hangups:
+			_ = d.Reload()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// dialNic is only called by the supervisor s
+func (d *daemon) dialNic(s *supervisor) error {
+	ctx, nic := s.ctx, s.nic
+	d.mutex.Lock()
+	accounts, ok := d.config.Accounts[nic]
+	active := d.active[nic]
+	tracking := d.tracking
+	d.mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("no account is configured for %s", nic)
+	}
+
+	dialer := d.dialer()
+	if tracking {
+		// address changes drop the cached client
+		client, err := s.client(dialer.config)
+		if err != nil {
+			return err
+		}
+		dialer.client = &client
+		dialer.linkRestarted = s.linkRestarted
+	}
+	online, families, active, err := dialer.dial(ctx, nic, accounts, active)
+	if err == nil && !online {
+		err = fmt.Errorf("%s is still offline", nic)
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if ctx.Err() != nil {
+		// the account was removed or changed while dialing
+		return err
+	}
+	d.active[nic] = active
+	state := d.states[nic]
+	state.Username = accounts[active].Username
+	state.Online = online
+	state.Families = families
+	state.LastDial = time.Now()
+	state.LastError = ""
+	if err != nil {
+		state.LastError = err.Error()
+	}
+	d.states[nic] = state
+	return err
+}
+
+func (d *daemon) Dial(ctx context.Context, nic string) error {
+	d.mutex.Lock()
+	s, ok := d.supervisors[nic]
+	paused := d.states[nic].Paused
+	d.mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("no account is configured for %s", nic)
+	}
+	if paused {
+		return fmt.Errorf("%s is paused", nic)
+	}
+	return s.trigger(ctx)
+}
+
+func (d *daemon) States() []control.NicState {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	states := make([]control.NicState, 0, len(d.states))
+	for _, nic := range slices.Sorted(maps.Keys(d.states)) {
+		states = append(states, d.states[nic])
+	}
+	return states
+}
+
+func (d *daemon) Pause(nic string) error {
+	return d.setPaused(nic, true)
+}
+
+func (d *daemon) Resume(nic string) error {
+	return d.setPaused(nic, false)
+}
+
+func (d *daemon) setPaused(nic string, paused bool) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	state, ok := d.states[nic]
+	if !ok {
+		return fmt.Errorf("no account is configured for %s", nic)
+	}
+	state.Paused = paused
+	d.states[nic] = state
+	if paused {
+		d.log.Info("paused %s", nic)
+	} else {
+		d.log.Info("resumed %s", nic)
+	}
+	return nil
+}
+
+// Reload parses and validates the configuration file again, and only applies it if it is valid.
+// Interfaces that were added, or whose account changed, are dialed immediately
+func (d *daemon) Reload() error {
+	config, log, err := d.loadConfig()
+	if err != nil {
+		d.dialer().log.Warning("keeping the running configuration: %s", err)
+		return err
+	}
+
+	added, removed := d.apply(*config, log)
+	log.Info("configuration reloaded, %d interface(s) added and %d removed", added, removed)
+	return nil
+}
+
+func serveMetrics(ctx context.Context, listen string, m *metrics.Metrics, log logger.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	server := http.Server{Addr: listen, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	log.Info("serving metrics on %s", listen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Warning("metrics listener stopped: %s", err)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/vishvananda/netlink"
+	"net"
+	"nuist_rover/alert"
+	"nuist_rover/configuration"
+	"nuist_rover/logger"
+	"nuist_rover/metrics"
+	"nuist_rover/nuistnet"
+	"nuist_rover/nuistnet/model"
+	"nuist_rover/onlinecheck"
+	"nuist_rover/retry"
+	"slices"
+	"sync"
+	"time"
+)
+
+// dialer signs accounts in and reports what it finds to the optional daemon facilities
+type dialer struct {
+	config  configuration.Root
+	log     logger.Logger
+	monitor *alert.Monitor
+	metrics *metrics.Metrics
+	// client is used instead of creating a new one for each dial if not nil
+	client *nuistnet.Client
+	// linkRestarted is called around restarting the link if not nil
+	linkRestarted func()
+}
+
+func (d dialer) dial_all_parallel(ctx context.Context, accounts map[string][]model.Account) {
+	var wg sync.WaitGroup
+	wg.Add(len(accounts))
+	for nic, nicAccounts := range accounts {
+		go func() {
+			defer wg.Done()
+			d.dial(ctx, nic, nicAccounts, 0)
+		}()
+	}
+	wg.Wait()
+}
+
+// dial signs one of the accounts in on nic unless it is online already. Accounts are tried in order
+// starting at active, failing over to the next one whenever the portal turns one down.
+// It returns whether nic ends up online, whether each address family is online,
+// the index of the account holding the link and the last error encountered
+func (d dialer) dial(ctx context.Context, nic string, accounts []model.Account, active int) (bool, map[string]bool, int, error) {
+	config, log := d.config, d.log.With(logger.NIC, nic)
+	remainingTrails := config.Retry + 1
+	if active < 0 || active >= len(accounts) {
+		active = 0
+	}
+	account := accounts[active]
+	client, err := d.clientOf(nic)
+	if err != nil {
+		log.Exception("failed to create client on %s: %s", nic, err)
+		return false, map[string]bool{"ipv4": false}, active, err
+	}
+
+	checkStart := time.Now()
+	signedIn, checked, err := onlinecheck.CheckOnline(ctx, config, nic, client, log)
+	checkDuration := time.Since(checkStart)
+	if config.OnlineCheck.Enabled {
+		d.metrics.ObserveOnlineCheck(nic, checkDuration)
+	}
+	if err != nil {
+		log.With(logger.DURATION, checkDuration).Warning("online check failed: %s", err)
+	} else if signedIn {
+		log.Info("already online on %s", nic)
+		families := familiesOf(client, func(*net.TCPAddr) bool { return true })
+		if len(checked) > 0 {
+			families = checked
+		}
+		d.metrics.SetOnline(nic, families)
+		d.observeStateBalance(ctx, nic, account, client)
+		return true, families, active, nil
+	}
+
+	var lastErr error
+	failures, attempt := 0, 0
+	turnedDown, hopeless, gaveUp := 0, true, false
+	for remainingTrails > 0 {
+		account = accounts[active]
+		attempt++
+		attemptLog := log.With(logger.ACCOUNT, account.Username).With(logger.ATTEMPT, attempt)
+		d.metrics.SigninAttempted(nic)
+		attemptStart := time.Now()
+		responses, err := client.SigninWithContext(account, ctx)
+		attemptLog = attemptLog.With(logger.DURATION, time.Since(attemptStart))
+		lastErr = err
+		successful := len(responses) > 0
+		if !successful && alreadyOnline(err) {
+			// retrying or restarting the link would only drop the working session
+			attemptLog.Info("%s is signed in on %s already", account.Username, nic)
+			families := familiesOf(client, func(*net.TCPAddr) bool { return true })
+			d.metrics.SetOnline(nic, families)
+			d.observeStateBalance(ctx, nic, account, client)
+			return true, families, active, nil
+		}
+		if err != nil {
+			var level logger.LogLevel
+			if !successful {
+				level = logger.EXCEPTION
+			} else {
+				level = logger.WARNING
+			}
+			logNicError(attemptLog, level, err, "failed to dial via %s using %s", nic, account.Username)
+		}
+
+		if !successful {
+			d.metrics.SigninFailed(nic)
+			hopeless = hopeless && permanent(err)
+			if turnedDown++; turnedDown < len(accounts) && rejected(err) {
+				// failing over takes no retrial until every account has been turned down
+				active = (active + 1) % len(accounts)
+				log.Info("failing over to %s on %s", accounts[active].Username, nic)
+				continue
+			}
+			if hopeless {
+				log.Exception("giving up on %s, retrying cannot help", nic)
+				gaveUp = true
+				break
+			}
+			turnedDown, hopeless = 0, true
+			remainingTrails -= 1
+			failures++
+			log.Log("%d retrial(s) remaining", remainingTrails)
+			if remainingTrails > 0 {
+				d.metrics.Retried(nic)
+				delay := config.RetryPolicy.Delay(failures)
+				log.Log("waiting %s before next retry", delay.String())
+				if err := retry.Sleep(ctx, delay); err != nil {
+					return false, familiesOf(client, func(*net.TCPAddr) bool { return false }), active, lastErr
+				}
+			}
+		} else {
+			attemptLog.Info("dial succeeded on %s", nic)
+			d.metrics.SigninSucceeded(nic)
+			families := familiesOf(client, func(addr *net.TCPAddr) bool {
+				_, ok := responses[addr]
+				return ok
+			})
+			d.metrics.SetOnline(nic, families)
+			for _, response := range responses {
+				if balance, err := response.ParsedBalance(); err == nil {
+					d.observeBalance(ctx, nic, account, balance)
+					break
+				}
+			}
+			return true, families, active, nil
+		}
+	}
+	families := familiesOf(client, func(*net.TCPAddr) bool { return false })
+	d.metrics.SetOnline(nic, families)
+
+	if config.RestartLink && ctx.Err() == nil && !gaveUp {
+		log.Info("retry expired, interface %s is restarting", nic)
+		d.metrics.LinkRestarted(nic)
+		// marked both before and after, as the link may be reported up before restartLink returns
+		if d.linkRestarted != nil {
+			d.linkRestarted()
+		}
+		d.restartLink(ctx, nic)
+		if d.linkRestarted != nil {
+			d.linkRestarted()
+		}
+	}
+	return false, families, active, lastErr
+}
+
+// familiesOf tells whether each address family of the client is online, which it is
+// if signedIn holds for every local address of it. IPv4 is reported offline without any address
+func familiesOf(client nuistnet.Client, signedIn func(addr *net.TCPAddr) bool) map[string]bool {
+	families := make(map[string]bool)
+	for _, addr := range client.LocalAddrs() {
+		family := nuistnet.Family(addr.IP)
+		online := signedIn(addr)
+		if known, ok := families[family]; ok {
+			online = online && known
+		}
+		families[family] = online
+	}
+	if len(families) <= 0 {
+		families["ipv4"] = false
+	}
+	return families
+}
+
+// logNicError logs every failure aggregated in err on its own line, with its local address attached
+func logNicError(log logger.Logger, level logger.LogLevel, err error, format string, args ...any) {
+	var aggregated *model.AggregatedNicError
+	if !errors.As(err, &aggregated) {
+		log.Println(level, format+": %s", append(slices.Clip(args), err)...)
+		return
+	}
+	for _, nicErr := range aggregated.Errors() {
+		log.With(logger.LOCAL_ADDR, nicErr.LocalAddr).Println(level, format+": %s", append(slices.Clip(args), nicErr.Err)...)
+	}
+}
+
+// alreadyOnline tells whether the portal refused signing in from every address because the account
+// is signed in from there already
+func alreadyOnline(err error) bool {
+	var aggregated *model.AggregatedNicError
+	if !errors.As(err, &aggregated) {
+		return errors.Is(err, model.ErrAlreadyOnline)
+	}
+	for _, nicErr := range aggregated.Errors() {
+		if !errors.Is(nicErr, model.ErrAlreadyOnline) {
+			return false
+		}
+	}
+	return true
+}
+
+// rejected tells whether the portal turned the account itself down, so another account may do better
+func rejected(err error) bool {
+	return permanent(err) || errors.Is(err, model.ErrTooManySessions)
+}
+
+// permanent tells whether signing the same account in again is bound to fail the same way
+func permanent(err error) bool {
+	return errors.Is(err, model.ErrBadCredentials) ||
+		errors.Is(err, model.ErrNoBalance) ||
+		errors.Is(err, model.ErrIspUnavailable)
+}
+
+func (d dialer) clientOf(nic string) (nuistnet.Client, error) {
+	if d.client != nil {
+		return *d.client, nil
+	}
+	return newClient(d.config, nic)
+}
+
+const linkSetUpAttempts = 3
+
+func (d dialer) restartLink(ctx context.Context, nic string) {
+	log := d.log
+	lo, err := netlink.LinkByName(nic)
+	if err != nil {
+		log.Error("%s was not found: %s", nic, err)
+		return
+	}
+	err = netlink.LinkSetDown(lo)
+	if err != nil {
+		log.Error("failed to set down %s: %s", nic, err)
+		return
+	}
+
+	for failures := 1; failures < linkSetUpAttempts; failures++ {
+		if err = netlink.LinkSetUp(lo); err == nil {
+			return
+		}
+		log.Warning("failed to set up %s, retrying: %s", nic, err)
+		if d.config.RetryPolicy.Wait(ctx, failures) != nil {
+			// never leave the link down, not even when shutting down
+			break
+		}
+	}
+	if err = netlink.LinkSetUp(lo); err != nil {
+		log.Error("failed to set up %s: %s", nic, err)
+	}
+}
+
+func (d dialer) observeBalance(ctx context.Context, nic string, account model.Account, balance model.Money) {
+	d.metrics.SetBalance(nic, balance)
+	d.monitor.Observe(ctx, nic, account.Username, balance, d.log)
+}
+
+// observeStateBalance queries the balance of an interface that is already online
+func (d dialer) observeStateBalance(ctx context.Context, nic string, account model.Account, client nuistnet.Client) {
+	if d.monitor == nil && d.metrics == nil {
+		return
+	}
+
+	queryCtx, cancelQueryCtx := context.WithTimeout(ctx, 10*time.Second)
+	defer cancelQueryCtx()
+	states, err := client.QueryState(queryCtx)
+	for _, state := range states {
+		if balance, err := state.ParsedBalance(); err == nil {
+			d.observeBalance(ctx, nic, account, balance)
+			return
+		}
+	}
+	if err != nil {
+		d.log.Warning("cannot query balance on %s: %s", nic, err)
+	}
+}
+
+func logout_all_parallel(ctx context.Context, accounts map[string][]model.Account, config configuration.Root, log logger.Logger) {
+	var wg sync.WaitGroup
+	wg.Add(len(accounts))
+	for nic, nicAccounts := range accounts {
+		go func() {
+			defer wg.Done()
+			logout(ctx, nic, nicAccounts, config, log)
+		}()
+	}
+	wg.Wait()
+}
+
+// logout signs off whichever of the accounts the portal reports online on nic, or the first one
+func logout(ctx context.Context, nic string, accounts []model.Account, config configuration.Root, log logger.Logger) {
+	client, err := newClient(config, nic)
+	if err != nil {
+		log.Exception("failed to create client on %s: %s", nic, err)
+		return
+	}
+
+	account := accounts[0]
+	if len(accounts) > 1 {
+		states, _ := client.QueryState(ctx)
+	search:
+		for _, state := range states {
+			for _, candidate := range accounts {
+				if state.UserName == candidate.Username {
+					account = candidate
+					break search
+				}
+			}
+		}
+	}
+
+	responses, err := client.SignoutWithContext(account, ctx)
+	if err != nil {
+		var level logger.LogLevel
+		if len(responses) <= 0 {
+			level = logger.EXCEPTION
+		} else {
+			level = logger.WARNING
+		}
+		logNicError(log.With(logger.NIC, nic).With(logger.ACCOUNT, account.Username), level, err,
+			"failed to sign off %s on %s", account.Username, nic)
+	}
+	if len(responses) > 0 {
+		log.Info("signed off %s on %s", account.Username, nic)
+	}
+}
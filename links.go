@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"github.com/vishvananda/netlink"
+	"net"
+	"nuist_rover/logger"
+	"syscall"
+)
+
+// trackLinks subscribes to address and link updates. A configured interface that gains an address,
+// or whose link comes up, has its client rebuilt and is dialed immediately
+func (d *daemon) trackLinks(ctx context.Context, log logger.Logger) {
+	done := make(chan struct{})
+	defer close(done)
+	addrUpdates := make(chan netlink.AddrUpdate, 16)
+	linkUpdates := make(chan netlink.LinkUpdate, 16)
+	if err := netlink.AddrSubscribe(addrUpdates, done); err != nil {
+		log.Warning("cannot subscribe to address updates, addresses are looked up on every dial: %s", err)
+		return
+	}
+	// existing links are listed first, so that a link coming up later is told apart
+	err := netlink.LinkSubscribeWithOptions(linkUpdates, done, netlink.LinkSubscribeOptions{ListExisting: true})
+	if err != nil {
+		log.Warning("cannot subscribe to link updates, addresses are looked up on every dial: %s", err)
+		return
+	}
+	d.mutex.Lock()
+	d.tracking = true
+	d.mutex.Unlock()
+	defer func() {
+		d.mutex.Lock()
+		d.tracking = false
+		d.mutex.Unlock()
+	}()
+
+	up := make(map[int]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-addrUpdates:
+			if !ok {
+				return
+			}
+			link, err := netlink.LinkByIndex(update.LinkIndex)
+			if err != nil {
+				continue
+			}
+			nic := link.Attrs().Name
+			if !d.configured(nic) {
+				continue
+			}
+			if !update.NewAddr {
+				d.refresh(nic, false, log)
+			} else if d.tracksFamily(update.LinkAddress.IP) {
+				log.With(logger.NIC, nic).Info("%s gained address %s", nic, update.LinkAddress.IP.String())
+				d.refresh(nic, true, log)
+			}
+		case update, ok := <-linkUpdates:
+			if !ok {
+				return
+			}
+			attrs := update.Attrs()
+			isUp := attrs.Flags&net.FlagUp != 0 && attrs.RawFlags&syscall.IFF_RUNNING != 0
+			wasUp, known := up[attrs.Index]
+			up[attrs.Index] = isUp
+			if isUp && known && !wasUp && d.configured(attrs.Name) {
+				if d.restartedRecently(attrs.Name) {
+					// dialing again right away would skip the backoff and restart the link back-to-back
+					log.With(logger.NIC, attrs.Name).Log("link %s is up again after restarting it", attrs.Name)
+					d.refresh(attrs.Name, false, log)
+					continue
+				}
+				log.With(logger.NIC, attrs.Name).Info("link %s is up", attrs.Name)
+				d.refresh(attrs.Name, true, log)
+			}
+		}
+	}
+}
+
+// configured tells whether nic is dialed by the daemon, as updates arrive for every interface of the host
+func (d *daemon) configured(nic string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	_, ok := d.supervisors[nic]
+	return ok
+}
+
+// restartedRecently tells whether a dial of nic restarted its link shortly before
+func (d *daemon) restartedRecently(nic string) bool {
+	d.mutex.Lock()
+	s, ok := d.supervisors[nic]
+	d.mutex.Unlock()
+	return ok && s.restartedRecently()
+}
+
+// tracksFamily tells whether clients send from addresses like ip
+func (d *daemon) tracksFamily(ip net.IP) bool {
+	if ip.IsLinkLocalUnicast() {
+		return false
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return ip.To4() != nil || d.config.IPv6
+}
+
+// refresh drops the cached client of nic, and dials it unless it is paused or dial is false
+func (d *daemon) refresh(nic string, dial bool, log logger.Logger) {
+	d.mutex.Lock()
+	s, ok := d.supervisors[nic]
+	paused := d.states[nic].Paused
+	d.mutex.Unlock()
+	if !ok {
+		return
+	}
+	if !dial || paused {
+		s.invalidate()
+		return
+	}
+	log.With(logger.NIC, nic).Log("dialing %s after its addresses changed", nic)
+	s.refresh()
+}
@@ -1,7 +1,12 @@
 package logger
 
+import "time"
+
 type Logger struct {
 	Level LogLevel
+	// Output receives every entry at or above Level, defaults to text on stdout
+	Output Output
+	fields []Field
 }
 
 type LogLevel int
@@ -14,3 +19,38 @@ const (
 	ERROR
 	UNKNOWN
 )
+
+type Format int
+
+const (
+	TEXT Format = iota
+	LOGFMT
+	JSON
+)
+
+// well known field keys
+const (
+	NIC        = "nic"
+	ACCOUNT    = "account"
+	LOCAL_ADDR = "local_addr"
+	ATTEMPT    = "attempt"
+	DURATION   = "duration"
+	FAMILY     = "family"
+)
+
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Entry is one formatted log line and the fields attached to it
+type Entry struct {
+	Time    time.Time
+	Level   LogLevel
+	Message string
+	Fields  []Field
+}
+
+type Output interface {
+	Write(entry Entry) error
+}
@@ -16,3 +16,14 @@ func (l LogLevel) Name() string {
 		return "unknown"
 	}
 }
+
+func (f Format) Name() string {
+	switch f {
+	case LOGFMT:
+		return "logfmt"
+	case JSON:
+		return "json"
+	default:
+		return "text"
+	}
+}
@@ -3,14 +3,33 @@ package logger
 import (
 	"fmt"
 	"os"
+	"slices"
 	"time"
 )
 
+var defaultOutput = NewStreamOutput(os.Stdout, TEXT)
+
+// With returns a logger attaching the field to every entry
+func (l Logger) With(key string, value any) Logger {
+	l.fields = append(slices.Clip(l.fields), Field{key, value})
+	return l
+}
+
 func (l Logger) Println(level LogLevel, format string, args ...any) {
 	if l.Level <= level {
-		timestamp := time.Now().Format("2006-01-02 15:04:05")
-		fmt.Printf("[%s] [%s] ", timestamp, level.Name())
-		fmt.Printf(format+"\n", args...)
+		output := l.Output
+		if output == nil {
+			output = defaultOutput
+		}
+		err := output.Write(Entry{
+			Time:    time.Now(),
+			Level:   level,
+			Message: fmt.Sprintf(format, args...),
+			Fields:  l.fields,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot write log: %s\n", err)
+		}
 	}
 }
 
@@ -27,7 +46,7 @@ func (l Logger) Warning(format string, args ...any) {
 }
 
 func (l Logger) Exception(format string, args ...any) {
-	l.Println(EXCEPTION, format, args)
+	l.Println(EXCEPTION, format, args...)
 }
 
 func (l Logger) Error(format string, args ...any) {
@@ -14,3 +14,15 @@ func ParseLevel(name string) LogLevel {
 		return UNKNOWN
 	}
 }
+
+// ParseFormat returns TEXT for empty or unknown names
+func ParseFormat(name string) Format {
+	switch name {
+	case "logfmt":
+		return LOGFMT
+	case "json":
+		return JSON
+	default:
+		return TEXT
+	}
+}
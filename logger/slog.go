@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogOutput hands entries to a log/slog handler, so that embedders can plug in their own
+type SlogOutput struct {
+	Handler slog.Handler
+}
+
+func (s SlogOutput) Write(entry Entry) error {
+	level := SlogLevel(entry.Level)
+	if !s.Handler.Enabled(context.Background(), level) {
+		return nil
+	}
+	record := slog.NewRecord(entry.Time, level, entry.Message, 0)
+	for _, field := range entry.Fields {
+		record.AddAttrs(slog.Any(field.Key, field.Value))
+	}
+	return s.Handler.Handle(context.Background(), record)
+}
+
+// SlogLevel maps a LogLevel to the closest log/slog level
+func SlogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LOG:
+		return slog.LevelDebug
+	case INFO:
+		return slog.LevelInfo
+	case WARNING:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
@@ -0,0 +1,122 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StreamOutput formats entries one per line to a writer
+type StreamOutput struct {
+	Writer io.Writer
+	Format Format
+	mutex  sync.Mutex
+}
+
+func NewStreamOutput(w io.Writer, format Format) *StreamOutput {
+	return &StreamOutput{Writer: w, Format: format}
+}
+
+func (s *StreamOutput) Write(entry Entry) error {
+	var buffer bytes.Buffer
+	switch s.Format {
+	case LOGFMT:
+		writeLogfmt(&buffer, entry)
+	case JSON:
+		if err := writeJson(&buffer, entry); err != nil {
+			return err
+		}
+	default:
+		writeText(&buffer, entry)
+	}
+	buffer.WriteByte('\n')
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err := s.Writer.Write(buffer.Bytes())
+	return err
+}
+
+func writeText(buffer *bytes.Buffer, entry Entry) {
+	fmt.Fprintf(buffer, "[%s] [%s] %s", entry.Time.Format("2006-01-02 15:04:05"), entry.Level.Name(), entry.Message)
+	for _, field := range entry.Fields {
+		fmt.Fprintf(buffer, " %s=%s", field.Key, logfmtValue(field.Value))
+	}
+}
+
+func writeLogfmt(buffer *bytes.Buffer, entry Entry) {
+	fmt.Fprintf(buffer, "time=%s level=%s msg=%s",
+		entry.Time.Format(time.RFC3339), entry.Level.Name(), logfmtValue(entry.Message))
+	for _, field := range entry.Fields {
+		fmt.Fprintf(buffer, " %s=%s", field.Key, logfmtValue(field.Value))
+	}
+}
+
+func logfmtValue(value any) string {
+	text := fmt.Sprint(value)
+	if len(text) <= 0 || strings.ContainsAny(text, " =\"\\\n\t") {
+		return strconv.Quote(text)
+	}
+	return text
+}
+
+func writeJson(buffer *bytes.Buffer, entry Entry) error {
+	buffer.WriteByte('{')
+	fields := append([]Field{
+		{"time", entry.Time.Format(time.RFC3339Nano)},
+		{"level", entry.Level.Name()},
+		{"msg", entry.Message},
+	}, entry.Fields...)
+	for index, field := range fields {
+		if index > 0 {
+			buffer.WriteByte(',')
+		}
+		key, err := json.Marshal(field.Key)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(jsonValue(field.Value))
+		if err != nil {
+			return fmt.Errorf("cannot encode field %s: %s", field.Key, err)
+		}
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return nil
+}
+
+func jsonValue(value any) any {
+	switch v := value.(type) {
+	case time.Duration:
+		return v.String()
+	case json.Marshaler:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
+
+// MultiOutput writes every entry to each of its outputs
+type MultiOutput []Output
+
+func (m MultiOutput) Write(entry Entry) error {
+	var problems []error
+	for _, output := range m {
+		if err := output.Write(entry); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return errors.Join(problems...)
+}
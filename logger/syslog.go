@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"log/syslog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LocalSyslogSocket is where the local syslog daemon listens, logd on OpenWrt included
+const LocalSyslogSocket = "/dev/log"
+
+// SyslogOutput sends entries to the local syslog daemon, logd on OpenWrt, or a remote one
+type SyslogOutput struct {
+	network  string
+	address  string
+	tag      string
+	hostname string
+	// local drops the hostname and uses the short timestamp expected on unix sockets
+	local bool
+
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+// DialSyslog connects to the local /dev/log socket if address is empty or "local",
+// or to a remote daemon given as udp://host:port or tcp://host:port
+func DialSyslog(address string, tag string) (*SyslogOutput, error) {
+	if len(address) <= 0 || address == "local" {
+		return DialLocalSyslog(LocalSyslogSocket, tag)
+	}
+
+	parsed, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog address %s: %s", address, err)
+	}
+	if parsed.Scheme != "udp" && parsed.Scheme != "tcp" {
+		return nil, fmt.Errorf("unsupported syslog network %s", parsed.Scheme)
+	}
+	hostname, _ := os.Hostname()
+	s := &SyslogOutput{network: parsed.Scheme, address: parsed.Host, tag: tag, hostname: hostname}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// DialLocalSyslog connects to a syslog daemon listening on the unix socket at path
+func DialLocalSyslog(path string, tag string) (*SyslogOutput, error) {
+	for _, network := range []string{"unixgram", "unix"} {
+		s := &SyslogOutput{network: network, address: path, tag: tag, local: true}
+		if err := s.connect(); err == nil {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot connect to syslog socket %s", path)
+}
+
+func (s *SyslogOutput) connect() error {
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	conn, err := net.Dial(s.network, s.address)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *SyslogOutput) Write(entry Entry) error {
+	var buffer bytes.Buffer
+	buffer.WriteString(entry.Message)
+	for _, field := range entry.Fields {
+		fmt.Fprintf(&buffer, " %s=%s", field.Key, logfmtValue(field.Value))
+	}
+
+	var severity syslog.Priority
+	switch entry.Level {
+	case LOG:
+		severity = syslog.LOG_DEBUG
+	case INFO:
+		severity = syslog.LOG_INFO
+	case WARNING:
+		severity = syslog.LOG_WARNING
+	case EXCEPTION:
+		severity = syslog.LOG_ERR
+	default:
+		severity = syslog.LOG_CRIT
+	}
+	return s.send(syslog.LOG_DAEMON|severity, buffer.String())
+}
+
+// send writes one message, reconnecting once if the daemon went away, e.g. when logd restarts
+func (s *SyslogOutput) send(priority syslog.Priority, message string) error {
+	if !strings.HasSuffix(message, "\n") {
+		message += "\n"
+	}
+	var line string
+	if s.local {
+		line = fmt.Sprintf("<%d>%s %s[%d]: %s", priority, time.Now().Format(time.Stamp), s.tag, os.Getpid(), message)
+	} else {
+		line = fmt.Sprintf("<%d>%s %s %s[%d]: %s", priority, time.Now().Format(time.RFC3339), s.hostname, s.tag, os.Getpid(), message)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn != nil {
+		if _, err := s.conn.Write([]byte(line)); err == nil {
+			return nil
+		}
+	}
+	if err := s.connect(); err != nil {
+		return err
+	}
+	_, err := s.conn.Write([]byte(line))
+	return err
+}
+
+func (s *SyslogOutput) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
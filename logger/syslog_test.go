@@ -0,0 +1,149 @@
+package logger
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenSyslog stands in for a syslog daemon on a local udp socket
+func listenSyslog(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("cannot listen on udp: %s", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// listenLocalSyslog stands in for logd on a unixgram socket like /dev/log
+func listenLocalSyslog(t *testing.T) (*net.UnixConn, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("cannot listen on unixgram: %s", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, path
+}
+
+func receiveSyslog(t *testing.T, conn net.PacketConn) string {
+	t.Helper()
+	buffer := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buffer)
+	if err != nil {
+		t.Fatalf("no syslog message received: %s", err)
+	}
+	return string(buffer[:n])
+}
+
+func TestSyslogSeverities(t *testing.T) {
+	conn := listenSyslog(t)
+	output, err := DialSyslog("udp://"+conn.LocalAddr().String(), "nuist_rover")
+	if err != nil {
+		t.Fatalf("DialSyslog: %s", err)
+	}
+	defer output.Close()
+	testSeverities(t, output, conn)
+}
+
+func TestLocalSyslogSeverities(t *testing.T) {
+	conn, path := listenLocalSyslog(t)
+	output, err := DialLocalSyslog(path, "nuist_rover")
+	if err != nil {
+		t.Fatalf("DialLocalSyslog: %s", err)
+	}
+	defer output.Close()
+	testSeverities(t, output, conn)
+}
+
+func TestLocalSyslogFormat(t *testing.T) {
+	conn, path := listenLocalSyslog(t)
+	output, err := DialLocalSyslog(path, "nuist_rover")
+	if err != nil {
+		t.Fatalf("DialLocalSyslog: %s", err)
+	}
+	defer output.Close()
+
+	if err := output.Write(Entry{Level: INFO, Message: "hello", Fields: []Field{{NIC, "wan"}}}); err != nil {
+		t.Fatalf("Write: %s", err)
+	}
+	// local daemons take no hostname and a short timestamp, like "Oct 18 05:54:03"
+	message := receiveSyslog(t, conn)
+	timestamp, rest, _ := strings.Cut(strings.TrimPrefix(message, "<30>"), " nuist_rover[")
+	if _, err := time.Parse(time.Stamp, timestamp); err != nil {
+		t.Errorf("local message %q has no short timestamp after the priority: %s", message, err)
+	}
+	if !strings.HasSuffix(rest, "]: hello nic=wan\n") {
+		t.Errorf("local message %q does not end in the tag, message and fields", message)
+	}
+}
+
+func TestDialLocalSyslogWithoutDaemon(t *testing.T) {
+	if output, err := DialLocalSyslog(filepath.Join(t.TempDir(), "log"), "nuist_rover"); err == nil {
+		_ = output.Close()
+		t.Error("DialLocalSyslog succeeded without a daemon listening")
+	}
+}
+
+func testSeverities(t *testing.T, output *SyslogOutput, conn net.PacketConn) {
+	t.Helper()
+	// facility daemon is 3, so the priority is 3*8 plus the severity
+	tests := []struct {
+		level    LogLevel
+		priority string
+	}{
+		{LOG, "<31>"},
+		{INFO, "<30>"},
+		{WARNING, "<28>"},
+		{EXCEPTION, "<27>"},
+		{ERROR, "<26>"},
+	}
+
+	for _, test := range tests {
+		if err := output.Write(Entry{Time: time.Now(), Level: test.level, Message: "hello"}); err != nil {
+			t.Fatalf("Write at %s: %s", test.level.Name(), err)
+		}
+		message := receiveSyslog(t, conn)
+		if !strings.HasPrefix(message, test.priority) {
+			t.Errorf("%s is sent as %q, want priority %s", test.level.Name(), message, test.priority)
+		}
+		if !strings.Contains(message, "nuist_rover") || !strings.Contains(message, "hello") {
+			t.Errorf("%s is sent as %q, want tag and message", test.level.Name(), message)
+		}
+	}
+}
+
+func TestSyslogThroughLogger(t *testing.T) {
+	conn := listenSyslog(t)
+	output, err := DialSyslog("udp://"+conn.LocalAddr().String(), "nuist_rover")
+	if err != nil {
+		t.Fatalf("DialSyslog: %s", err)
+	}
+	defer output.Close()
+
+	log := Logger{Level: LOG, Output: output}.With(NIC, "wan")
+	log.Warning("balance of %s is %s", "20231234567", "1.00")
+
+	message := receiveSyslog(t, conn)
+	if !strings.HasPrefix(message, "<28>") {
+		t.Errorf("warning is sent as %q, want priority <28>", message)
+	}
+	if !strings.Contains(message, "balance of 20231234567 is 1.00 nic=wan") {
+		t.Errorf("warning is sent as %q, want the message followed by its fields", message)
+	}
+}
+
+func TestDialSyslogRejectsUnknownNetwork(t *testing.T) {
+	for _, address := range []string{"unix:///dev/log", "http://localhost:514", "::"} {
+		if output, err := DialSyslog(address, "nuist_rover"); err == nil {
+			_ = output.Close()
+			t.Errorf("DialSyslog(%q) succeeded, want error", address)
+		}
+	}
+}
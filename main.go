@@ -4,170 +4,216 @@ import (
 	"context"
 	"fmt"
 	"github.com/alecthomas/kong"
-	"github.com/vishvananda/netlink"
 	"nuist_rover/configuration"
 	"nuist_rover/logger"
 	"nuist_rover/nuistnet"
 	"nuist_rover/nuistnet/model"
-	"nuist_rover/onlinecheck"
+	"nuist_rover/nuistnet/trace"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 )
 
-var args struct {
+type globals struct {
 	Configuration string `short:"c" optional:"" help:"Name of the configuration file." type:"file"`
-	Retry         bool
-	Daemon        bool   `short:"D"`
 	Verbose       string `enum:"log,info,warning,exception,unknown" default:"unknown"`
+	Trace         string `optional:"" help:"Record every exchange with the authentication server to this JSON lines file. Passwords are redacted." type:"path"`
 }
 
-func main() {
-	kong.Parse(&args)
-	if len(args.Configuration) <= 0 {
-		args.Configuration = "/etc/nuistrover/config.toml"
-	}
+// tracer is opened by globals.load if --trace is given
+var tracer *trace.File
 
-	config, err := configuration.Parse(args.Configuration)
-	if err != nil {
-		fmt.Println(err.Error())
-		return
-	}
-
-	var log logger.Logger
-	log.Level = parseLogLevel(args.Verbose, config.Verbose)
+// logFile and syslogOutput are opened by globals.load if [log] file or syslog is configured
+var (
+	logFile      *logger.RotatingFile
+	syslogOutput *logger.SyslogOutput
+)
 
-	if config.Retry > 0 || args.Retry {
-		config.Retry = max(config.Retry, 1)
-	}
+var cli struct {
+	globals
+
+	Login   loginCmd   `cmd:"" default:"withargs" help:"Sign in on the configured interface(s)."`
+	Status  statusCmd  `cmd:"" help:"Show whether the configured interface(s) are online."`
+	Logout  logoutCmd  `cmd:"" help:"Sign the configured account(s) off the portal."`
+	Daemon  daemonCmd  `cmd:"" help:"Keep signing in on every configured interface periodically."`
+	Control controlCmd `cmd:"" help:"Talk to a running daemon over the control socket."`
+	Config  struct {
+		Check           configCheckCmd           `cmd:"" help:"Validate the configuration file."`
+		EncryptPassword configEncryptPasswordCmd `cmd:"" help:"Encrypt a password read from stdin for password_encrypted."`
+	} `cmd:"" help:"Inspect the configuration file."`
+	Replay replayCmd `cmd:"" help:"Decode the responses recorded by --trace again."`
+}
 
-	if len(config.ServerUrl) <= 0 {
-		log.Warning("server url has empty value")
+func main() {
+	parser := kong.Must(&cli, kong.Name("nuist_rover"))
+	kctx, err := parser.Parse(legacyArgs(os.Args[1:]))
+	parser.FatalIfErrorf(err)
+	err = kctx.Run(&cli.globals)
+	if tracer != nil {
+		_ = tracer.Close()
 	}
-
-	if args.Daemon && config.TestInterval <= 0 {
-		config.TestInterval = 1 * time.Minute
-		log.Info("running in daemon mode while test interval has empty value, defaulting to %s", config.TestInterval.String())
+	if logFile != nil {
+		_ = logFile.Close()
 	}
-
-	if config.RetryInterval <= 0 {
-		config.RetryInterval = 30 * time.Second
-		log.Info("retry interval has empty value, defaulting to %s", config.RetryInterval.String())
+	if syslogOutput != nil {
+		_ = syslogOutput.Close()
 	}
+	kctx.FatalIfErrorf(err)
+}
 
-	log.Log("loaded %d account(s)", len(config.Accounts))
-	ctx, cancelCtx := context.WithCancel(context.Background())
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
-
-	if args.Daemon {
-		dial_all_parallel(ctx, *config, log)
-
-		ticker := time.NewTicker(config.TestInterval)
-		for {
-			select {
-			case <-ticker.C:
-				go dial_all_parallel(ctx, *config, log)
-
-			case sig := <-signals:
-				cancelCtx()
-				log.Log("%s", sig.String())
-				return
-			}
+// legacyArgs turns the -D / --daemon flag of the CLI before commands into the daemon command,
+// so that existing init scripts running `nuist_rover -D` keep working. --retry needs no mapping
+// as login, the default command, and daemon both take it
+func legacyArgs(args []string) []string {
+	var mapped []string
+	daemon, command := false, false
+	for i, arg := range args {
+		if arg == "--" {
+			mapped = append(mapped, args[i:]...)
+			break
 		}
-	} else {
-		done := make(chan struct{})
-		go func() {
-			dial_all_parallel(ctx, *config, log)
-			close(done)
-		}()
-		select {
-		case <-done:
-		case sig := <-signals:
-			cancelCtx()
-			log.Log("%s", sig.String())
-			<-done
+		switch arg {
+		case "-D", "--daemon":
+			daemon = true
+			continue
+		case "daemon":
+			command = true
 		}
+		mapped = append(mapped, arg)
+	}
+	if !daemon {
+		return args
+	}
+	fmt.Fprintln(os.Stderr, "nuist_rover: -D / --daemon is deprecated, use the daemon command instead")
+	if command {
+		return mapped
 	}
+	return append([]string{"daemon"}, mapped...)
 }
 
-func dial_all_parallel(ctx context.Context, config configuration.Root, log logger.Logger) {
-	var wg sync.WaitGroup
-	wg.Add(len(config.Accounts))
-	for nic, account := range config.Accounts {
-		go func() {
-			defer wg.Done()
-			dial(ctx, nic, account, config, log)
-		}()
+func (g *globals) load() (*configuration.Root, logger.Logger, error) {
+	if len(g.Configuration) <= 0 {
+		g.Configuration = "/etc/nuistrover/config.toml"
 	}
-	wg.Wait()
-}
 
-func dial(ctx context.Context, nic string, account model.Account, config configuration.Root, log logger.Logger) {
-	remainingTrails := config.Retry + 1
-	client, err := nuistnet.NewClient(config.ServerUrl, nic)
+	var log logger.Logger
+	config, err := configuration.Parse(g.Configuration)
 	if err != nil {
-		panic(err)
+		return nil, log, err
 	}
 
-	signedIn, err := onlinecheck.CheckOnline(ctx, config, nic, client, log)
+	log.Level = parseLogLevel(g.Verbose, config.Verbose)
+	log.Output, err = openLogOutput(config.Log)
 	if err != nil {
-		log.Warning("online check failed: %s", err)
-	} else if signedIn {
-		log.Info("already online on %s", nic)
-		return
+		return nil, log, err
 	}
 
-	for remainingTrails > 0 {
-		responses, err := client.SigninWithContext(account, ctx)
-		successful := len(responses) > 0
+	if len(g.Trace) > 0 && tracer == nil {
+		tracer, err = trace.Create(g.Trace)
 		if err != nil {
-			var level logger.LogLevel
-			if !successful {
-				level = logger.EXCEPTION
-			} else {
-				level = logger.WARNING
-			}
-			log.Println(level, "failed to dial via %s using %s: %s", nic, account.Username, err)
+			return nil, log, fmt.Errorf("cannot open trace file: %s", err)
 		}
+		log.Info("tracing to %s", g.Trace)
+	}
 
-		if !successful {
-			remainingTrails -= 1
-			log.Log("%d retrial(s) remaining", remainingTrails)
-			if remainingTrails > 0 {
-				log.Log("waiting %s before next retry", config.RetryInterval.String())
-				time.Sleep(config.RetryInterval)
-			}
-		} else {
-			log.Info("dial succeeded on %s", nic)
-			return
-		}
+	if len(config.ServerUrl) <= len("http://") {
+		log.Warning("server url has empty value")
+	}
+
+	if config.RetryPolicy.Interval <= 0 {
+		config.RetryPolicy.Interval = 30 * time.Second
+		log.Info("retry interval has empty value, defaulting to %s", config.RetryPolicy.Interval.String())
 	}
 
-	if config.RestartLink {
-		log.Info("retry expired, interface %s is restarting", nic)
-		lo, err := netlink.LinkByName(nic)
+	log.Log("loaded account(s) for %d interface(s)", len(config.Accounts))
+	return config, log, nil
+}
+
+// openLogOutput opens the configured log file and syslog connection once, and writes to stdout
+// if neither is configured
+func openLogOutput(config configuration.Log) (logger.Output, error) {
+	var err error
+	if len(config.File) > 0 && logFile == nil {
+		logFile, err = logger.OpenRotatingFile(config.File, int64(config.MaxSize)*1024, config.Backups)
 		if err != nil {
-			log.Error("%s was not found: %s", nic, err)
-			return
+			return nil, fmt.Errorf("cannot open log file: %s", err)
 		}
-		err = netlink.LinkSetDown(lo)
+	}
+	if len(config.Syslog) > 0 && syslogOutput == nil {
+		syslogOutput, err = logger.DialSyslog(config.Syslog, "nuistrover")
 		if err != nil {
-			log.Error("failed to set down %s: %s", nic, err)
-			return
+			return nil, fmt.Errorf("cannot connect to syslog: %s", err)
 		}
-		err = netlink.LinkSetUp(lo)
-		if err != nil {
-			log.Error("failed to set up %s: %s", nic, err)
+	}
+
+	format := logger.ParseFormat(config.Format)
+	var outputs logger.MultiOutput
+	if logFile != nil {
+		outputs = append(outputs, logger.NewStreamOutput(logFile, format))
+	}
+	if syslogOutput != nil {
+		outputs = append(outputs, syslogOutput)
+	}
+	switch len(outputs) {
+	case 0:
+		if len(config.Format) <= 0 {
+			return nil, nil
 		}
+		return logger.NewStreamOutput(os.Stdout, format), nil
+	case 1:
+		return outputs[0], nil
+	default:
+		return outputs, nil
+	}
+}
+
+// runInterruptible runs fn until it returns or SIGINT / SIGTERM is received,
+// in which case the context passed to fn is cancelled and fn is waited for
+func runInterruptible(log logger.Logger, fn func(ctx context.Context)) {
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	defer cancelCtx()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	done := make(chan struct{})
+	go func() {
+		fn(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case sig := <-signals:
+		cancelCtx()
+		log.Log("%s", sig.String())
+		<-done
 	}
 }
 
+func newClient(config configuration.Root, nic string) (nuistnet.Client, error) {
+	client, err := nuistnet.NewClientWithOptions(config.ServerUrl, nic, nuistnet.ClientOptions{IPv6: config.IPv6})
+	if tracer != nil {
+		client.Tracer = tracer
+	}
+	return client, err
+}
+
+func selectAccounts(config configuration.Root, nic string) (map[string][]model.Account, error) {
+	if len(nic) <= 0 {
+		return config.Accounts, nil
+	}
+	accounts, ok := config.Accounts[nic]
+	if !ok {
+		return nil, fmt.Errorf("no account is configured for %s", nic)
+	}
+	return map[string][]model.Account{nic: accounts}, nil
+}
+
 func parseLogLevel(args ...string) logger.LogLevel {
+	unknown := ""
 	for _, item := range args {
-		if len(item) <= 0 || item == "unknown" || len(item) <= 0 {
+		if len(item) <= 0 || item == "unknown" {
 			continue
 		}
 
@@ -175,12 +221,12 @@ func parseLogLevel(args ...string) logger.LogLevel {
 		if parsed != logger.UNKNOWN {
 			return parsed
 		}
+		unknown = item
 	}
 
-	if len(args) > 0 {
-		fmt.Printf("unknown log level: %s\n", args[len(args)-1])
+	if len(unknown) > 0 {
+		fmt.Printf("unknown log level: %s\n", unknown)
 	}
 
 	return logger.UNKNOWN
 }
-
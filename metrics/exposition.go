@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+type sample struct {
+	suffix string
+	// labels are added after the nic label
+	labels [][2]string
+	value  float64
+}
+
+type family struct {
+	name    string
+	kind    string
+	help    string
+	samples func(n *nicMetrics) []sample
+}
+
+var families = []family{
+	{"nuistrover_online", "gauge", "Whether the address family is online on the interface (1) or not (0).", func(n *nicMetrics) []sample {
+		samples := make([]sample, 0, len(n.online))
+		for _, family := range slices.Sorted(maps.Keys(n.online)) {
+			s := sample{labels: [][2]string{{"family", family}}}
+			if n.online[family] {
+				s.value = 1
+			}
+			samples = append(samples, s)
+		}
+		return samples
+	}},
+	{"nuistrover_signin_attempts_total", "counter", "Signin requests sent through the interface.", func(n *nicMetrics) []sample {
+		return []sample{{value: float64(n.signinAttempts)}}
+	}},
+	{"nuistrover_signin_successes_total", "counter", "Signin requests that succeeded.", func(n *nicMetrics) []sample {
+		return []sample{{value: float64(n.signinSuccesses)}}
+	}},
+	{"nuistrover_signin_failures_total", "counter", "Signin requests that failed.", func(n *nicMetrics) []sample {
+		return []sample{{value: float64(n.signinFailures)}}
+	}},
+	{"nuistrover_retries_total", "counter", "Signin retries used.", func(n *nicMetrics) []sample {
+		return []sample{{value: float64(n.retries)}}
+	}},
+	{"nuistrover_link_restarts_total", "counter", "Times the interface was restarted after retries expired.", func(n *nicMetrics) []sample {
+		return []sample{{value: float64(n.linkRestarts)}}
+	}},
+	{"nuistrover_last_successful_dial_timestamp_seconds", "gauge", "Unix time of the last successful signin.", func(n *nicMetrics) []sample {
+		if n.lastSuccessfulDial.IsZero() {
+			return nil
+		}
+		return []sample{{value: float64(n.lastSuccessfulDial.UnixMilli()) / 1000}}
+	}},
+	{"nuistrover_online_check_duration_seconds", "summary", "Time spent on online checks.", func(n *nicMetrics) []sample {
+		return []sample{{suffix: "_sum", value: n.checkSeconds}, {suffix: "_count", value: float64(n.checkCount)}}
+	}},
+	{"nuistrover_balance_yuan", "gauge", "Account balance last reported by the portal.", func(n *nicMetrics) []sample {
+		if n.balance == nil {
+			return nil
+		}
+		return []sample{{value: n.balance.Yuan()}}
+	}},
+}
+
+// Write writes every metric in Prometheus text exposition format
+func (m *Metrics) Write(w io.Writer) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	buffer := bufio.NewWriter(w)
+	nics := slices.Sorted(maps.Keys(m.nics))
+	for _, f := range families {
+		fmt.Fprintf(buffer, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(buffer, "# TYPE %s %s\n", f.name, f.kind)
+		for _, nic := range nics {
+			for _, s := range f.samples(m.nics[nic]) {
+				labels := "nic=" + strconv.Quote(nic)
+				for _, label := range s.labels {
+					labels += "," + label[0] + "=" + strconv.Quote(label[1])
+				}
+				fmt.Fprintf(buffer, "%s%s{%s} %s\n", f.name, s.suffix, labels, strconv.FormatFloat(s.value, 'g', -1, 64))
+			}
+		}
+	}
+	return buffer.Flush()
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.Write(w)
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"maps"
+	"nuist_rover/nuistnet/model"
+	"sync"
+	"time"
+)
+
+type nicMetrics struct {
+	// online tells whether each address family is online
+	online             map[string]bool
+	signinAttempts     uint64
+	signinSuccesses    uint64
+	signinFailures     uint64
+	retries            uint64
+	linkRestarts       uint64
+	lastSuccessfulDial time.Time
+	checkCount         uint64
+	checkSeconds       float64
+	balance            *model.Money
+}
+
+// Metrics collects per-interface daemon statistics. All methods are no-op on a nil receiver
+type Metrics struct {
+	mutex sync.Mutex
+	nics  map[string]*nicMetrics
+}
+
+func New() *Metrics {
+	return &Metrics{nics: make(map[string]*nicMetrics)}
+}
+
+func (m *Metrics) update(nic string, fn func(n *nicMetrics)) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	n, ok := m.nics[nic]
+	if !ok {
+		n = &nicMetrics{}
+		m.nics[nic] = n
+	}
+	fn(n)
+}
+
+// SetOnline records whether each address family, "ipv4" or "ipv6", is online on nic
+func (m *Metrics) SetOnline(nic string, families map[string]bool) {
+	m.update(nic, func(n *nicMetrics) { n.online = maps.Clone(families) })
+}
+
+func (m *Metrics) SigninAttempted(nic string) {
+	m.update(nic, func(n *nicMetrics) { n.signinAttempts++ })
+}
+
+func (m *Metrics) SigninSucceeded(nic string) {
+	m.update(nic, func(n *nicMetrics) {
+		n.signinSuccesses++
+		n.lastSuccessfulDial = time.Now()
+	})
+}
+
+func (m *Metrics) SigninFailed(nic string) {
+	m.update(nic, func(n *nicMetrics) { n.signinFailures++ })
+}
+
+func (m *Metrics) Retried(nic string) {
+	m.update(nic, func(n *nicMetrics) { n.retries++ })
+}
+
+func (m *Metrics) LinkRestarted(nic string) {
+	m.update(nic, func(n *nicMetrics) { n.linkRestarts++ })
+}
+
+func (m *Metrics) ObserveOnlineCheck(nic string, latency time.Duration) {
+	m.update(nic, func(n *nicMetrics) {
+		n.checkCount++
+		n.checkSeconds += latency.Seconds()
+	})
+}
+
+func (m *Metrics) SetBalance(nic string, balance model.Money) {
+	m.update(nic, func(n *nicMetrics) { n.balance = &balance })
+}
@@ -6,15 +6,29 @@ import (
 	"net"
 	"net/http"
 	"net/netip"
+	"nuist_rover/nuistnet/trace"
+	"slices"
 )
 
 type Client struct {
 	ServerUrl    string
 	NicInterface net.Interface
-	clients      map[net.Addr]http.Client
+	// Tracer records every exchange with the server if not nil
+	Tracer  trace.Tracer
+	clients map[net.Addr]http.Client
 }
 
+type ClientOptions struct {
+	// IPv6 also sends from the global IPv6 addresses of the interface
+	IPv6 bool
+}
+
+// NewClient sends from the IPv4 addresses of the interface only
 func NewClient(serverUrl string, nicName string) (Client, error) {
+	return NewClientWithOptions(serverUrl, nicName, ClientOptions{})
+}
+
+func NewClientWithOptions(serverUrl string, nicName string, options ClientOptions) (Client, error) {
 	interfaces, err := net.Interfaces()
 	if err != nil {
 		return Client{}, err
@@ -37,7 +51,10 @@ func NewClient(serverUrl string, nicName string) (Client, error) {
 	client := make(map[net.Addr]http.Client)
 	for _, addr := range addresses {
 		localAddr, err := getTcpAddr(addr)
-		if err != nil || localAddr.IP.IsLinkLocalUnicast() || localAddr.IP.IsLinkLocalMulticast() || localAddr.IP.To4() == nil {
+		if err != nil || localAddr.IP.IsLinkLocalUnicast() || localAddr.IP.IsLinkLocalMulticast() {
+			continue
+		}
+		if localAddr.IP.To4() == nil && !options.IPv6 {
 			continue
 		}
 		dialer := net.Dialer{LocalAddr: localAddr}
@@ -51,6 +68,42 @@ func NewClient(serverUrl string, nicName string) (Client, error) {
 	}, err
 }
 
+// LocalAddrs returns the addresses the client sends from, IPv4 ones first
+func (c Client) LocalAddrs() []*net.TCPAddr {
+	addrs := make([]*net.TCPAddr, 0, len(c.clients))
+	for addr := range c.clients {
+		addrs = append(addrs, addr.(*net.TCPAddr))
+	}
+	slices.SortFunc(addrs, func(a, b *net.TCPAddr) int {
+		return a.AddrPort().Compare(b.AddrPort())
+	})
+	return addrs
+}
+
+// LocalAddrOf returns the first local address of the family, which is either "ipv4" or "ipv6"
+func (c Client) LocalAddrOf(family string) (*net.TCPAddr, bool) {
+	for _, addr := range c.LocalAddrs() {
+		if Family(addr.IP) == family {
+			return addr, true
+		}
+	}
+	return nil, false
+}
+
+// Family is "ipv4" or "ipv6"
+func Family(ip net.IP) string {
+	if ip.To4() != nil {
+		return "ipv4"
+	}
+	return "ipv6"
+}
+
+// HttpClient returns the client bound to local address addr
+func (c Client) HttpClient(addr net.Addr) (http.Client, bool) {
+	client, ok := c.clients[addr]
+	return client, ok
+}
+
 func getTcpAddr(addr net.Addr) (*net.TCPAddr, error) {
 	switch addr := addr.(type) {
 	case *net.IPNet:
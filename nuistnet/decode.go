@@ -0,0 +1,105 @@
+package nuistnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"nuist_rover/nuistnet/helper"
+	"nuist_rover/nuistnet/isp"
+	"nuist_rover/nuistnet/model"
+	"nuist_rover/nuistnet/trace"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// decodeCharset converts a raw body in the charset announced by contentType to utf-8
+func decodeCharset(contentType string, raw []byte) ([]byte, error) {
+	reader, err := helper.DecodeBody(contentType, bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", model.ErrMalformedResponse, err)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", model.ErrMalformedResponse, err)
+	}
+	return decoded, nil
+}
+
+func decodeResponse[Data any](buffer []byte) (*Data, error) {
+	var responseBodyBase model.Response[any]
+	err := json.Unmarshal(buffer, &responseBodyBase)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", model.ErrMalformedResponse, err)
+	}
+
+	if responseBodyBase.Code != 200 {
+		return nil, model.NewPortalError(responseBodyBase.Code, responseBodyBase.Message)
+	}
+
+	var responseBody model.Response[Data]
+
+	err = json.Unmarshal(buffer, &responseBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", model.ErrMalformedResponse, err)
+	}
+
+	return &responseBody.Data, nil
+}
+
+func decodeIspMapping(buffer []byte) (map[isp.Type]int, error) {
+	acceptableHttpCode := []int{200, 201, 202}
+	var responseBody model.Response[model.ListChannelsContent]
+	err := json.Unmarshal(buffer, &responseBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", model.ErrMalformedResponse, err)
+	}
+	if !slices.Contains(acceptableHttpCode, responseBody.Code) {
+		return nil, model.NewPortalError(responseBody.Code, responseBody.Message)
+	}
+
+	mapping := make(map[isp.Type]int, len(responseBody.Data.Channels))
+	for _, channel := range responseBody.Data.Channels {
+		id, err := strconv.Atoi(channel.Id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: error parsing channel ID (raw: %s): %w", model.ErrMalformedResponse, channel.Id, err)
+		}
+		mapping[isp.Parse(channel.Name)] = id
+	}
+	return mapping, nil
+}
+
+// Replay feeds a recorded response through the decoder the client would have used for it
+func Replay(record trace.Record) (any, error) {
+	if len(record.Error) > 0 && record.Body == nil {
+		return nil, fmt.Errorf("no response was recorded: %s", record.Error)
+	}
+	body, err := decodeCharset(record.Headers.Get("Content-Type"), record.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := url.Parse(record.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(endpoint.Path, "/api/v1/pre_login"):
+		return decodeResponse[model.StateQueryContent](body)
+	case strings.HasSuffix(endpoint.Path, "/api/v1/logout"):
+		return decodeResponse[model.SignoutContent](body)
+	case strings.HasSuffix(endpoint.Path, "/api/v1/login"):
+		var req model.NuistNetSignReq
+		if err := json.Unmarshal(record.Plaintext, &req); err != nil {
+			return nil, fmt.Errorf("cannot tell the stage of the request: %s", err)
+		}
+		if req.Pagesign == "firstauth" {
+			return decodeIspMapping(body)
+		}
+		return decodeResponse[model.SigninContent](body)
+	default:
+		return nil, fmt.Errorf("unknown endpoint %s", record.Endpoint)
+	}
+}
@@ -42,3 +42,42 @@ func pkcs7Pad(data []byte, blockSize int) []byte {
 	padText := bytes.Repeat([]byte{byte(padding)}, padding)
 	return append(data, padText...)
 }
+
+func Decrypt(key string, data string) (string, error) {
+	ciphertext, err := hex.DecodeString(data)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return "", err
+	}
+	if len(ciphertext) <= 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return "", fmt.Errorf("ciphertext is not a multiple of block size")
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	for i := 0; i < len(ciphertext); i += aes.BlockSize {
+		block.Decrypt(plaintext[i:i+aes.BlockSize], ciphertext[i:i+aes.BlockSize])
+	}
+	unpadded, err := pkcs7Unpad(plaintext, aes.BlockSize)
+	if err != nil {
+		return "", err
+	}
+	return string(unpadded), nil
+}
+
+// pkcs7Unpad rejects anything but well-formed padding, which is also how a wrong key usually shows up
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	if len(data) <= 0 || len(data)%blockSize != 0 {
+		return nil, fmt.Errorf("padded data is not a multiple of block size")
+	}
+	padding := int(data[len(data)-1])
+	if padding <= 0 || padding > blockSize {
+		return nil, fmt.Errorf("invalid padding length %d", padding)
+	}
+	if !bytes.Equal(data[len(data)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, fmt.Errorf("invalid padding bytes")
+	}
+	return data[:len(data)-padding], nil
+}
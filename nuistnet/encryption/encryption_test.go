@@ -0,0 +1,117 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"testing"
+)
+
+// vectors computed independently with `openssl enc -aes-128-ecb`
+var vectors = []struct {
+	key        string
+	plaintext  string
+	ciphertext string
+}{
+	{ENCRYPTION_KEY, "20231234567", "5a59d23c7619eb041424c24de74ec3fa"},
+	// a whole block of plaintext gets a whole block of padding
+	{ENCRYPTION_KEY, "0123456789abcdef", "11869b297cbfa0b5e4d765c74596a18f1836d3799483ed16df9dff224d6b02f9"},
+}
+
+func TestGenerateEncryptionKey(t *testing.T) {
+	// first 8 bytes of sha256("axaQiQpsdFAacccs20231234567")
+	if key := GenerateEncryptionKey("20231234567"); key != "c9d4453446f84de9" {
+		t.Errorf("GenerateEncryptionKey = %s, want c9d4453446f84de9", key)
+	}
+}
+
+func TestEncryptKnownVectors(t *testing.T) {
+	for _, vector := range vectors {
+		ciphertext, err := Encrypt(vector.key, vector.plaintext)
+		if err != nil {
+			t.Errorf("Encrypt(%q) returned error: %s", vector.plaintext, err)
+			continue
+		}
+		if ciphertext != vector.ciphertext {
+			t.Errorf("Encrypt(%q) = %s, want %s", vector.plaintext, ciphertext, vector.ciphertext)
+		}
+	}
+}
+
+func TestDecryptKnownVectors(t *testing.T) {
+	for _, vector := range vectors {
+		plaintext, err := Decrypt(vector.key, vector.ciphertext)
+		if err != nil {
+			t.Errorf("Decrypt(%s) returned error: %s", vector.ciphertext, err)
+			continue
+		}
+		if plaintext != vector.plaintext {
+			t.Errorf("Decrypt(%s) = %q, want %q", vector.ciphertext, plaintext, vector.plaintext)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	key := GenerateEncryptionKey("20231234567")
+	for _, plaintext := range []string{"", "0", "secondauth", "0123456789abcdef", "10.255.1.23", "密码 with spaces"} {
+		ciphertext, err := Encrypt(key, plaintext)
+		if err != nil {
+			t.Errorf("Encrypt(%q) returned error: %s", plaintext, err)
+			continue
+		}
+		decrypted, err := Decrypt(key, ciphertext)
+		if err != nil {
+			t.Errorf("Decrypt(Encrypt(%q)) returned error: %s", plaintext, err)
+			continue
+		}
+		if decrypted != plaintext {
+			t.Errorf("Decrypt(Encrypt(%q)) = %q", plaintext, decrypted)
+		}
+	}
+}
+
+func TestDecryptRejectsMalformedCiphertext(t *testing.T) {
+	for _, ciphertext := range []string{"", "not hex", "5a59d23c7619eb04", "5a59d23c7619eb041424c24de74ec3fa00"} {
+		if plaintext, err := Decrypt(ENCRYPTION_KEY, ciphertext); err == nil {
+			t.Errorf("Decrypt(%q) = %q, want error", ciphertext, plaintext)
+		}
+	}
+}
+
+func TestPkcs7Unpad(t *testing.T) {
+	block := func(tail ...byte) []byte {
+		return append(bytes.Repeat([]byte{'a'}, aes.BlockSize-len(tail)), tail...)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []byte
+		wantErr bool
+	}{
+		{name: "one byte of padding", data: block(1), want: bytes.Repeat([]byte{'a'}, 15)},
+		{name: "three bytes of padding", data: block(3, 3, 3), want: bytes.Repeat([]byte{'a'}, 13)},
+		{name: "whole block of padding", data: append(block(), bytes.Repeat([]byte{16}, 16)...), want: block()},
+		{name: "padding length 0", data: block(0), wantErr: true},
+		{name: "padding length over block size", data: block(17), wantErr: true},
+		{name: "mismatched padding bytes", data: block(1, 2, 3), wantErr: true},
+		{name: "not a multiple of block size", data: block(1)[1:], wantErr: true},
+		{name: "empty", data: nil, wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := pkcs7Unpad(test.data, aes.BlockSize)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: pkcs7Unpad = %q, want error", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: pkcs7Unpad returned error: %s", test.name, err)
+			continue
+		}
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("%s: pkcs7Unpad = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package fakeportal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"nuist_rover/nuistnet/model"
+	"slices"
+	"sync"
+)
+
+type Stage int
+
+const (
+	LIST_CHANNELS Stage = iota
+	SIGNIN
+	SIGNOUT
+	QUERY_STATE
+)
+
+type Channel struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+var DefaultChannels = []Channel{
+	{"1", "校园网"},
+	{"2", "中国移动"},
+	{"3", "中国电信"},
+	{"4", "中国联通"},
+}
+
+// Request is a request the portal received, decrypted
+type Request struct {
+	Stage Stage
+	Sign  model.NuistNetSignReq
+	State model.NusitNetOnlineStateQueryReq
+}
+
+type account struct {
+	password string
+	balance  string
+}
+
+// Portal imitates the i-NUIST authentication server. Unless a reply is scripted for a stage,
+// it lists Channels, signs in accounts added by AddAccount and tracks which address is online
+type Portal struct {
+	Server   *httptest.Server
+	Channels []Channel
+	// url overrides the address of Server if not empty
+	url string
+
+	mutex    sync.Mutex
+	accounts map[string]account
+	online   map[string]string
+	scripts  map[Stage][]Reply
+	requests []Request
+}
+
+func New() *Portal {
+	p := newPortal()
+	p.Server = httptest.NewServer(p.handler())
+	return p
+}
+
+// NewDualStack serves on both IPv4 and IPv6 loopback under localhost, so that clients
+// can reach it from addresses of either family
+func NewDualStack() (*Portal, error) {
+	listener, err := net.Listen("tcp", "[::]:0")
+	if err != nil {
+		return nil, err
+	}
+	p := newPortal()
+	p.Server = httptest.NewUnstartedServer(p.handler())
+	_ = p.Server.Listener.Close()
+	p.Server.Listener = listener
+	p.Server.Start()
+	p.url = fmt.Sprintf("http://localhost:%d", listener.Addr().(*net.TCPAddr).Port)
+	return p, nil
+}
+
+func newPortal() *Portal {
+	return &Portal{
+		Channels: DefaultChannels,
+		accounts: make(map[string]account),
+		online:   make(map[string]string),
+		scripts:  make(map[Stage][]Reply),
+	}
+}
+
+func (p *Portal) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/login", p.handleSign)
+	mux.HandleFunc("POST /api/v1/logout", p.handleSign)
+	mux.HandleFunc("POST /api/v1/pre_login", p.handleState)
+	return mux
+}
+
+func (p *Portal) URL() string {
+	if len(p.url) > 0 {
+		return p.url
+	}
+	return p.Server.URL
+}
+
+func (p *Portal) Close() {
+	p.Server.Close()
+}
+
+func (p *Portal) AddAccount(username string, password string, balance string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.accounts[username] = account{password, balance}
+}
+
+// Script queues replies for the next requests of a stage, in order
+func (p *Portal) Script(stage Stage, replies ...Reply) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.scripts[stage] = append(p.scripts[stage], replies...)
+}
+
+func (p *Portal) Requests() []Request {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return slices.Clone(p.requests)
+}
+
+// OnlineUser returns who is signed in on an ip, if anyone
+func (p *Portal) OnlineUser(ip string) (string, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	username, ok := p.online[ip]
+	return username, ok
+}
+
+func (p *Portal) handleSign(w http.ResponseWriter, r *http.Request) {
+	var encrypted model.NuistNetSignReq
+	if err := json.NewDecoder(r.Body).Decode(&encrypted); err != nil {
+		malformedRequest(err).write(w)
+		return
+	}
+	req, err := encrypted.Decrypt()
+	if err != nil {
+		malformedRequest(err).write(w)
+		return
+	}
+
+	var stage Stage
+	switch req.Pagesign {
+	case "firstauth":
+		stage = LIST_CHANNELS
+	case "secondauth":
+		stage = SIGNIN
+	case "thirdauth":
+		stage = SIGNOUT
+	default:
+		malformedRequest(fmt.Errorf("unknown pagesign %s", req.Pagesign)).write(w)
+		return
+	}
+
+	p.reply(Request{Stage: stage, Sign: req}).write(w)
+}
+
+func (p *Portal) handleState(w http.ResponseWriter, r *http.Request) {
+	var encrypted model.NusitNetOnlineStateQueryReq
+	if err := json.NewDecoder(r.Body).Decode(&encrypted); err != nil {
+		malformedRequest(err).write(w)
+		return
+	}
+	req, err := encrypted.Decrypt()
+	if err != nil {
+		malformedRequest(err).write(w)
+		return
+	}
+
+	p.reply(Request{Stage: QUERY_STATE, State: req}).write(w)
+}
+
+// reply records the request and answers it with the next scripted reply or the default behaviour
+func (p *Portal) reply(req Request) Reply {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.requests = append(p.requests, req)
+	if scripted := p.scripts[req.Stage]; len(scripted) > 0 {
+		p.scripts[req.Stage] = scripted[1:]
+		return scripted[0]
+	}
+
+	switch req.Stage {
+	case LIST_CHANNELS:
+		return Success(map[string]any{"channels": p.Channels})
+	case SIGNIN:
+		acc, ok := p.accounts[req.Sign.Username]
+		if !ok || acc.password != req.Sign.Password {
+			return WrongPassword()
+		}
+		if !slices.ContainsFunc(p.Channels, func(c Channel) bool { return c.Id == req.Sign.Channel }) {
+			return Reply{Code: 400, Message: "所选通道不可用"}
+		}
+		p.online[req.Sign.UsrIpAdd] = req.Sign.Username
+		return Success(model.SigninContent{
+			Username:      req.Sign.Username,
+			Balance:       acc.balance,
+			Duration:      "0",
+			Outport:       req.Sign.Channel,
+			TotalTimespan: "0",
+			UsrIpAdd:      req.Sign.UsrIpAdd,
+		})
+	case SIGNOUT:
+		delete(p.online, req.Sign.UsrIpAdd)
+		return Success(model.SignoutContent{Username: req.Sign.Username, UsrIpAdd: req.Sign.UsrIpAdd})
+	default:
+		username, ok := p.online[req.State.UsrIpAdd]
+		if !ok {
+			return Success(model.StateQueryContent{OnlineState: "off", UsrIpAdd: req.State.UsrIpAdd})
+		}
+		return Success(model.StateQueryContent{
+			OnlineState:   "on",
+			UserName:      username,
+			Balance:       p.accounts[username].balance,
+			Duration:      "0",
+			TotalTimeSpan: "0",
+			UsrIpAdd:      req.State.UsrIpAdd,
+		})
+	}
+}
+
+func malformedRequest(err error) Reply {
+	return Reply{Status: http.StatusBadRequest, Code: 400, Message: err.Error()}
+}
@@ -0,0 +1,83 @@
+package fakeportal
+
+import (
+	"encoding/json"
+	"golang.org/x/text/encoding/ianaindex"
+	"net/http"
+	"nuist_rover/nuistnet/model"
+)
+
+// Reply is what the portal answers to one request
+type Reply struct {
+	// Status is the HTTP status, defaults to 200
+	Status  int
+	Code    int
+	Message string
+	Data    any
+	// Charset is announced in Content-Type and used to encode the body if it is supported, defaults to utf-8
+	Charset string
+	// ContentType is sent verbatim instead of the one announcing Charset if not empty,
+	// to script malformed headers
+	ContentType string
+	// Body is sent verbatim instead of the JSON envelope if not nil
+	Body []byte
+}
+
+func Success(data any) Reply {
+	return Reply{Code: 200, Message: "success", Data: data}
+}
+
+func WrongPassword() Reply {
+	return Reply{Code: 400, Message: "用户名或密码错误"}
+}
+
+func NoBalance() Reply {
+	return Reply{Code: 400, Message: "账户余额不足"}
+}
+
+func TooManySessions() Reply {
+	return Reply{Code: 400, Message: "该账号在线数已达上限"}
+}
+
+func RateLimited() Reply {
+	return Reply{Status: http.StatusTooManyRequests, Code: 429, Message: "请求过于频繁，请稍后再试"}
+}
+
+func Malformed() Reply {
+	return Reply{Body: []byte("<html><body>502 Bad Gateway</body></html>")}
+}
+
+func (r Reply) write(w http.ResponseWriter) {
+	body := r.Body
+	if body == nil {
+		var err error
+		body, err = json.Marshal(model.Response[any]{Code: r.Code, Message: r.Message, Data: r.Data})
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	charset := r.Charset
+	if len(charset) <= 0 {
+		charset = "utf-8"
+	}
+	// odd charsets the client is expected to reject are sent unencoded
+	if encoding, err := ianaindex.MIME.Encoding(charset); err == nil && encoding != nil {
+		body, err = encoding.NewEncoder().Bytes(body)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	contentType := r.ContentType
+	if len(contentType) <= 0 {
+		contentType = "application/json;charset=" + charset
+	}
+	w.Header().Set("Content-Type", contentType)
+	status := r.Status
+	if status <= 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
@@ -1,12 +1,23 @@
 package helper
 
-import "strings"
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
 
-func GetCharset(contentType string) string {
-	key := "charset="
-	index := strings.Index(contentType, key)
-	if index < 0 {
-		return "utf-8"
+// GetCharset returns the charset parameter of contentType, which defaults to utf-8
+func GetCharset(contentType string) (string, error) {
+	if len(strings.TrimSpace(contentType)) <= 0 {
+		return "utf-8", nil
 	}
-	return contentType[index+len(key):]
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("invalid content type %s: %s", contentType, err)
+	}
+	charset, ok := params["charset"]
+	if !ok || len(charset) <= 0 {
+		return "utf-8", nil
+	}
+	return charset, nil
 }
@@ -1,17 +1,31 @@
 package helper
 
 import (
+	"fmt"
 	"golang.org/x/text/encoding/ianaindex"
 	"golang.org/x/text/transform"
 	"io"
 	"net/http"
 )
 
-func GetBody(response *http.Response) io.Reader {
-	encoding, err := ianaindex.MIME.Encoding(GetCharset(response.Header.Get("Content-Type")))
+func GetBody(response *http.Response) (io.Reader, error) {
+	return DecodeBody(response.Header.Get("Content-Type"), response.Body)
+}
+
+// DecodeBody converts a body in the charset announced by contentType to utf-8
+func DecodeBody(contentType string, body io.Reader) (io.Reader, error) {
+	charset, err := GetCharset(contentType)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	encoding, err := ianaindex.MIME.Encoding(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unknown charset %s: %s", charset, err)
+	}
+	// registered charsets without a decoder are reported as nil without an error
+	if encoding == nil {
+		return nil, fmt.Errorf("unsupported charset %s", charset)
 	}
 
-	return transform.NewReader(response.Body, encoding.NewDecoder())
+	return transform.NewReader(body, encoding.NewDecoder()), nil
 }
@@ -0,0 +1,72 @@
+package helper
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestGetCharset(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+		wantErr     bool
+	}{
+		{contentType: "", want: "utf-8"},
+		{contentType: "application/json", want: "utf-8"},
+		{contentType: "application/json;charset=GBK", want: "GBK"},
+		{contentType: "application/json; charset=utf-8; foo=bar", want: "utf-8"},
+		{contentType: `application/json; charset="utf-8"`, want: "utf-8"},
+		{contentType: "application/json; charset", wantErr: true},
+		{contentType: ";;", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := GetCharset(test.contentType)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("GetCharset(%q) = %s, want error", test.contentType, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("GetCharset(%q) = %s, %v, want %s", test.contentType, got, err, test.want)
+		}
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	gbk := string([]byte{0xd6, 0xd0, 0xb9, 0xfa}) // 中国
+	tests := []struct {
+		contentType string
+		body        string
+		want        string
+		wantErr     bool
+	}{
+		{contentType: "application/json", body: "中国", want: "中国"},
+		{contentType: "application/json;charset=GBK", body: gbk, want: "中国"},
+		{contentType: `application/json;charset="gbk"; foo=bar`, body: gbk, want: "中国"},
+		{contentType: "application/json;charset=no-such-charset", wantErr: true},
+		// registered with IANA but without a decoder
+		{contentType: "application/json;charset=ISO-2022-CN", wantErr: true},
+		{contentType: "application/json; charset", wantErr: true},
+	}
+
+	for _, test := range tests {
+		reader, err := DecodeBody(test.contentType, strings.NewReader(test.body))
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("DecodeBody(%q) succeeded, want error", test.contentType)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("DecodeBody(%q) returned error: %s", test.contentType, err)
+			continue
+		}
+		got, err := io.ReadAll(reader)
+		if err != nil || string(got) != test.want {
+			t.Errorf("DecodeBody(%q) read %q, %v, want %q", test.contentType, got, err, test.want)
+		}
+	}
+}
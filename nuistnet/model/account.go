@@ -2,10 +2,16 @@ package model
 
 import (
 	"nuist_rover/nuistnet/isp"
+	"slices"
 )
 
 type Account struct {
 	Username string
 	Password string
-	Isp      isp.Type
+	// Isp lists the acceptable ISPs in order of preference
+	Isp []isp.Type
+}
+
+func (a Account) Equal(other Account) bool {
+	return a.Username == other.Username && a.Password == other.Password && slices.Equal(a.Isp, other.Isp)
 }
@@ -1,40 +1,127 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
 	"net"
+	"nuist_rover/nuistnet/isp"
+	"slices"
 	"strings"
 )
 
+// NicError is the failure of a request sent from one local address of an interface
+type NicError struct {
+	Nic       string
+	LocalAddr net.Addr
+	Endpoint  string
+	Err       error
+}
+
+func (e *NicError) Error() string {
+	return fmt.Sprintf("%s on %s %s requesting %s", e.Err, e.Nic, e.LocalAddr.String(), e.Endpoint)
+}
+
+func (e *NicError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON exposes the failure as flat fields for structured logs
+func (e *NicError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Nic       string `json:"nic"`
+		LocalAddr string `json:"local_addr"`
+		Endpoint  string `json:"endpoint"`
+		Error     string `json:"error"`
+	}{e.Nic, e.LocalAddr.String(), e.Endpoint, e.Err.Error()})
+}
+
+// AggregatedNicError collects the failures of a request multicast from every local address of an interface
 type AggregatedNicError struct {
-	errorMap map[net.Addr]error
+	errors []*NicError
 }
 
-func NewAggregatedNicError(errorMap map[net.Addr]error) *AggregatedNicError {
-	if len(errorMap) <= 0 {
+// NewAggregatedNicError returns nil if there is no failure, and orders failures by local address otherwise
+func NewAggregatedNicError(errors []*NicError) error {
+	if len(errors) <= 0 {
 		return nil
 	}
-	return &AggregatedNicError{errorMap}
+	sorted := slices.Clone(errors)
+	slices.SortStableFunc(sorted, func(a, b *NicError) int {
+		if c := strings.Compare(a.Nic, b.Nic); c != 0 {
+			return c
+		}
+		return strings.Compare(a.LocalAddr.String(), b.LocalAddr.String())
+	})
+	return &AggregatedNicError{sorted}
+}
+
+// Errors returns every failure ordered by interface and local address
+func (a *AggregatedNicError) Errors() []*NicError {
+	return slices.Clone(a.errors)
 }
 
 func (a *AggregatedNicError) GetErrors() map[net.Addr]error {
-	clone := make(map[net.Addr]error, len(a.errorMap))
-	for addr, err := range a.errorMap {
-		clone[addr] = err
+	errorMap := make(map[net.Addr]error, len(a.errors))
+	for _, err := range a.errors {
+		errorMap[err.LocalAddr] = err.Err
 	}
-	return clone
+	return errorMap
 }
 
 func (a *AggregatedNicError) GetError(addr net.Addr) error {
-	return a.errorMap[addr]
+	for _, err := range a.errors {
+		if err.LocalAddr == addr {
+			return err.Err
+		}
+	}
+	return nil
+}
+
+func (a *AggregatedNicError) Unwrap() []error {
+	errs := make([]error, len(a.errors))
+	for index, err := range a.errors {
+		errs[index] = err
+	}
+	return errs
+}
+
+func (a *AggregatedNicError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.errors)
 }
 
 func (a *AggregatedNicError) Error() string {
-	buffer := make([]string, len(a.errorMap))
-	index := 0
-	for addr, err := range a.errorMap {
-		buffer[index] = fmt.Sprintf("%s on %s", err, addr.String())
-		index++
+	buffer := make([]string, len(a.errors))
+	for index, err := range a.errors {
+		buffer[index] = err.Error()
 	}
 	return strings.Join(buffer, "\n")
 }
+
+// IspUnavailableError is returned when the portal lists none of the ISPs an account accepts
+type IspUnavailableError struct {
+	Preferred []isp.Type
+	Listed    map[isp.Type]int
+}
+
+func (e *IspUnavailableError) Error() string {
+	return fmt.Sprintf("none of the isp(s) %s is available, the portal lists %s",
+		ispNames(e.Preferred), ispNames(slices.Sorted(maps.Keys(e.Listed))))
+}
+
+func (e *IspUnavailableError) Unwrap() error {
+	return ErrIspUnavailable
+}
+
+func ispNames(types []isp.Type) string {
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		if name := isp.Name(t); len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) <= 0 {
+		return "nothing"
+	}
+	return strings.Join(names, ", ")
+}
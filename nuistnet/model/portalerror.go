@@ -0,0 +1,75 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrBadCredentials    = errors.New("bad credentials")
+	ErrNoBalance         = errors.New("no balance left")
+	ErrTooManySessions   = errors.New("too many sessions")
+	ErrIspUnavailable    = errors.New("isp unavailable")
+	ErrServerUnreachable = errors.New("could not connect to authentication server")
+	ErrMalformedResponse = errors.New("malformed response from authentication server")
+	ErrRateLimited       = errors.New("rate limited by authentication server")
+	// ErrAlreadyOnline means the account is signed in from this very address already
+	ErrAlreadyOnline = errors.New("already online")
+)
+
+// portalMessages maps fragments of the messages the portal replies with to what they mean.
+// Earlier entries win, and bare English words are left out as they show up in unrelated messages
+var portalMessages = []struct {
+	kind      error
+	fragments []string
+}{
+	{ErrBadCredentials, []string{"密码错误", "用户名或密码", "用户不存在", "账号不存在", "账户不存在"}},
+	{ErrNoBalance, []string{"余额不足", "欠费", "停机"}},
+	{ErrTooManySessions, []string{"在线数", "终端数", "已在其他"}},
+	{ErrIspUnavailable, []string{"通道不可用", "通道不存在", "运营商不可用"}},
+	{ErrRateLimited, []string{"频繁", "too many requests"}},
+	// e.g. "用户已在线", after "已在其他" so that another device being online doesn't match
+	{ErrAlreadyOnline, []string{"已在线", "已经在线"}},
+}
+
+// portalCodes maps the HTTP-like codes the portal replies with to what they mean,
+// for failures whose message is not recognized
+var portalCodes = map[int]error{
+	401: ErrBadCredentials,
+	402: ErrNoBalance,
+	429: ErrRateLimited,
+}
+
+// PortalError is a failure response from the authentication server
+type PortalError struct {
+	Code    int
+	Message string
+	// Kind is one of the Err* values above, or nil if the failure is not recognized
+	Kind error
+}
+
+// NewPortalError classifies a failure response by its message, falling back to its code
+// since the same code, e.g. 400, is used for many kinds of failure
+func NewPortalError(code int, message string) *PortalError {
+	lower := strings.ToLower(message)
+	for _, candidate := range portalMessages {
+		for _, fragment := range candidate.fragments {
+			if strings.Contains(lower, fragment) {
+				return &PortalError{code, message, candidate.kind}
+			}
+		}
+	}
+	return &PortalError{code, message, portalCodes[code]}
+}
+
+func (e *PortalError) Error() string {
+	if len(e.Message) <= 0 {
+		return fmt.Sprintf("failure response code %d from authentication server", e.Code)
+	}
+	return fmt.Sprintf("failure response code %d from authentication server: %s", e.Code, e.Message)
+}
+
+func (e *PortalError) Unwrap() error {
+	return e.Kind
+}
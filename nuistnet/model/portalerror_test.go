@@ -0,0 +1,64 @@
+package model
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestNewPortalError(t *testing.T) {
+	tests := []struct {
+		code    int
+		message string
+		want    error
+	}{
+		{code: 400, message: "用户名或密码错误", want: ErrBadCredentials},
+		{code: 400, message: "用户不存在", want: ErrBadCredentials},
+		{code: 400, message: "账户余额不足", want: ErrNoBalance},
+		{code: 400, message: "您的账号已欠费", want: ErrNoBalance},
+		{code: 400, message: "该账号在线数已达上限", want: ErrTooManySessions},
+		{code: 400, message: "账号已在其他设备登录", want: ErrTooManySessions},
+		{code: 400, message: "所选通道不可用", want: ErrIspUnavailable},
+		{code: 429, message: "请求过于频繁，请稍后再试", want: ErrRateLimited},
+		// the message takes precedence over the code
+		{code: 429, message: "用户名或密码错误", want: ErrBadCredentials},
+		// unrecognized messages fall back to the code
+		{code: 429, message: "", want: ErrRateLimited},
+		{code: 401, message: "unauthorized", want: ErrBadCredentials},
+		{code: 402, message: "", want: ErrNoBalance},
+		{code: 400, message: "用户已在线", want: ErrAlreadyOnline},
+		{code: 400, message: "该账号已在其他设备在线", want: ErrTooManySessions},
+		// bare English words are not recognized
+		{code: 400, message: "session expired", want: nil},
+		{code: 400, message: "balance query failed", want: nil},
+		{code: 400, message: "password expires soon", want: nil},
+		{code: 400, message: "channel busy", want: nil},
+		{code: 400, message: "未知错误", want: nil},
+		{code: 503, message: "系统维护中", want: nil},
+	}
+
+	for _, test := range tests {
+		err := NewPortalError(test.code, test.message)
+		if err.Kind != test.want {
+			t.Errorf("NewPortalError(%d, %q) is %v, want %v", test.code, test.message, err.Kind, test.want)
+		}
+		if test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("NewPortalError(%d, %q) does not unwrap to %s", test.code, test.message, test.want)
+		}
+	}
+}
+
+func TestPortalErrorThroughAggregatedNicError(t *testing.T) {
+	err := NewAggregatedNicError([]*NicError{
+		{Nic: "wan", LocalAddr: &net.TCPAddr{IP: net.IPv4(10, 255, 1, 23)}, Endpoint: "/api/v1/login", Err: NewPortalError(400, "账户余额不足")},
+		{Nic: "wan", LocalAddr: &net.TCPAddr{IP: net.IPv4(10, 255, 1, 24)}, Endpoint: "/api/v1/login", Err: NewPortalError(429, "")},
+	})
+
+	if !errors.Is(err, ErrNoBalance) || !errors.Is(err, ErrRateLimited) {
+		t.Errorf("%v does not unwrap to both portal errors", err)
+	}
+	var portalError *PortalError
+	if !errors.As(err, &portalError) || portalError.Code != 400 {
+		t.Errorf("%v does not unwrap to the first portal error", err)
+	}
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Money is an amount of CNY in fen, i.e. 1/100 yuan
+type Money int64
+
+// ParseMoney parses balances like "12.3", "12.30元" or "￥-0.5"
+func ParseMoney(raw string) (Money, error) {
+	trimmed := strings.TrimSpace(raw)
+	trimmed = strings.TrimPrefix(trimmed, "￥")
+	trimmed = strings.TrimPrefix(trimmed, "¥")
+	trimmed = strings.TrimSuffix(trimmed, "元")
+	trimmed = strings.TrimSpace(trimmed)
+	if len(trimmed) <= 0 {
+		return 0, fmt.Errorf("empty money amount")
+	}
+
+	negative := strings.HasPrefix(trimmed, "-")
+	trimmed = strings.TrimPrefix(trimmed, "-")
+	integral, fractional, _ := strings.Cut(trimmed, ".")
+	if len(integral) <= 0 && len(fractional) <= 0 {
+		// placeholders like "-" must not read as a zero balance
+		return 0, fmt.Errorf("invalid money amount %s", raw)
+	}
+	if len(integral) <= 0 {
+		integral = "0"
+	}
+	if len(fractional) > 2 {
+		// the portal never reports below fen, anything beyond is truncated
+		fractional = fractional[:2]
+	}
+	for len(fractional) < 2 {
+		fractional += "0"
+	}
+
+	yuan, err := strconv.ParseUint(integral, 10, 63)
+	if err != nil {
+		return 0, fmt.Errorf("invalid money amount %s", raw)
+	}
+	fen, err := strconv.ParseUint(fractional, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid money amount %s", raw)
+	}
+
+	amount := Money(yuan*100 + fen)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+func (m Money) Yuan() float64 {
+	return float64(m) / 100
+}
+
+func (m Money) String() string {
+	sign := ""
+	abs := m
+	if m < 0 {
+		sign = "-"
+		abs = -m
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100)
+}
+
+var timespanUnits = map[string]time.Duration{
+	"d":  24 * time.Hour,
+	"天":  24 * time.Hour,
+	"h":  time.Hour,
+	"时":  time.Hour,
+	"小时": time.Hour,
+	"m":  time.Minute,
+	"分":  time.Minute,
+	"分钟": time.Minute,
+	"s":  time.Second,
+	"秒":  time.Second,
+}
+
+// ParseTimespan parses durations as the portal reports them, which may be
+// a plain number of seconds, a clock reading like "12:34:56",
+// or a sequence of numbers with units like "1天2小时3分4秒" or "5h6m"
+func ParseTimespan(raw string) (time.Duration, error) {
+	trimmed := strings.TrimSpace(raw)
+	if len(trimmed) <= 0 {
+		return 0, fmt.Errorf("empty timespan")
+	}
+
+	if seconds, err := strconv.ParseUint(trimmed, 10, 63); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	if strings.Contains(trimmed, ":") {
+		return parseClockTimespan(trimmed)
+	}
+
+	var total time.Duration
+	remaining := trimmed
+	for len(remaining) > 0 {
+		numberEnd := strings.IndexFunc(remaining, func(r rune) bool { return !unicode.IsDigit(r) })
+		if numberEnd <= 0 {
+			return 0, fmt.Errorf("invalid timespan %s", raw)
+		}
+		value, err := strconv.ParseUint(remaining[:numberEnd], 10, 63)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timespan %s", raw)
+		}
+		remaining = strings.TrimSpace(remaining[numberEnd:])
+
+		unitEnd := strings.IndexFunc(remaining, unicode.IsDigit)
+		if unitEnd < 0 {
+			unitEnd = len(remaining)
+		}
+		unit, ok := timespanUnits[strings.TrimSpace(remaining[:unitEnd])]
+		if !ok {
+			return 0, fmt.Errorf("invalid timespan %s", raw)
+		}
+		total += time.Duration(value) * unit
+		remaining = remaining[unitEnd:]
+	}
+	return total, nil
+}
+
+func parseClockTimespan(raw string) (time.Duration, error) {
+	parts := strings.Split(raw, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timespan %s", raw)
+	}
+
+	var total time.Duration
+	for _, part := range parts {
+		value, err := strconv.ParseUint(strings.TrimSpace(part), 10, 63)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timespan %s", raw)
+		}
+		total = total*60 + time.Duration(value)
+	}
+	return total * time.Second, nil
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
@@ -0,0 +1,165 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    Money
+		wantErr bool
+	}{
+		{raw: "12.3", want: 1230},
+		{raw: "12.30", want: 1230},
+		{raw: "12.30元", want: 1230},
+		{raw: " 12.30 元 ", want: 1230},
+		{raw: "￥8", want: 800},
+		{raw: "¥0.05", want: 5},
+		{raw: "30", want: 3000},
+		{raw: "0.00", want: 0},
+		{raw: "-0.5", want: -50},
+		{raw: "￥-0.5", want: -50},
+		{raw: ".5", want: 50},
+		{raw: "-.5", want: -50},
+		{raw: "1.239", want: 123},
+		{raw: "", wantErr: true},
+		{raw: "元", wantErr: true},
+		{raw: "-", wantErr: true},
+		{raw: ".", wantErr: true},
+		{raw: "-.", wantErr: true},
+		{raw: "--1", wantErr: true},
+		{raw: "+1", wantErr: true},
+		{raw: "1.2.3", wantErr: true},
+		{raw: "1,000", wantErr: true},
+		{raw: "abc", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseMoney(test.raw)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q) = %s, want error", test.raw, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q) returned error: %s", test.raw, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseMoney(%q) = %s, want %s", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{money: 0, want: "0.00"},
+		{money: 5, want: "0.05"},
+		{money: 1230, want: "12.30"},
+		{money: -50, want: "-0.50"},
+	}
+
+	for _, test := range tests {
+		if got := test.money.String(); got != test.want {
+			t.Errorf("Money(%d).String() = %s, want %s", int64(test.money), got, test.want)
+		}
+	}
+}
+
+func TestParseTimespan(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{raw: "0", want: 0},
+		{raw: "3600", want: time.Hour},
+		{raw: "12:34:56", want: 12*time.Hour + 34*time.Minute + 56*time.Second},
+		{raw: "34:56", want: 34*time.Minute + 56*time.Second},
+		{raw: "100:00:00", want: 100 * time.Hour},
+		{raw: "1天2小时3分4秒", want: 26*time.Hour + 3*time.Minute + 4*time.Second},
+		{raw: "2时30分", want: 2*time.Hour + 30*time.Minute},
+		{raw: "45分钟", want: 45 * time.Minute},
+		{raw: "5h6m", want: 5*time.Hour + 6*time.Minute},
+		{raw: "1d 2h", want: 26 * time.Hour},
+		{raw: " 90s ", want: 90 * time.Second},
+		{raw: "", wantErr: true},
+		{raw: "-", wantErr: true},
+		{raw: "5", want: 5 * time.Second},
+		{raw: "5x", wantErr: true},
+		{raw: "h", wantErr: true},
+		{raw: "1:2:3:4", wantErr: true},
+		{raw: "12:ab", wantErr: true},
+		{raw: "-5", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseTimespan(test.raw)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseTimespan(%q) = %s, want error", test.raw, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTimespan(%q) returned error: %s", test.raw, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseTimespan(%q) = %s, want %s", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestParsedStateQueryContent(t *testing.T) {
+	// made-up state query reply in the shape the client expects, see IsOnline for the online states
+	raw := `{"code":200,"message":"ok","data":{"useronlinestate":"on","username":"20231234567","balance":"23.45","duration":"01:02:03","outport":"中国移动","totaltimespan":"3天4小时","useripadd":"10.255.1.23"}}`
+
+	var response Response[StateQueryContent]
+	if err := json.Unmarshal([]byte(raw), &response); err != nil {
+		t.Fatalf("decode state query reply: %s", err)
+	}
+
+	balance, err := response.Data.ParsedBalance()
+	if err != nil || balance != 2345 {
+		t.Errorf("balance = %s, %v, want 23.45", balance, err)
+	}
+	duration, err := response.Data.ParsedDuration()
+	if err != nil || duration != time.Hour+2*time.Minute+3*time.Second {
+		t.Errorf("duration = %s, %v, want 1h2m3s", duration, err)
+	}
+	total, err := response.Data.ParsedTotalTimespan()
+	if err != nil || total != 76*time.Hour {
+		t.Errorf("total timespan = %s, %v, want 76h", total, err)
+	}
+}
+
+func TestParsedSigninContent(t *testing.T) {
+	// made-up signin reply in the shape the client expects,
+	// with a placeholder instead of a balance
+	raw := `{"code":200,"message":"认证成功","data":{"reauth":false,"username":"20231234567","balance":"-","duration":"0","outport":"中国电信","totaltimespan":"12分钟","usripadd":"10.255.1.23"}}`
+
+	var response Response[SigninContent]
+	if err := json.Unmarshal([]byte(raw), &response); err != nil {
+		t.Fatalf("decode signin reply: %s", err)
+	}
+
+	if balance, err := response.Data.ParsedBalance(); err == nil {
+		t.Errorf("balance = %s, want error for placeholder", balance)
+	}
+	duration, err := response.Data.ParsedDuration()
+	if err != nil || duration != 0 {
+		t.Errorf("duration = %s, %v, want 0s", duration, err)
+	}
+	total, err := response.Data.ParsedTotalTimespan()
+	if err != nil || total != 12*time.Minute {
+		t.Errorf("total timespan = %s, %v, want 12m", total, err)
+	}
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type Response[Content any] struct {
 	Code    int     `json:"code"`
 	Message string  `json:"message"`
@@ -23,6 +25,11 @@ type SigninContent struct {
 	UsrIpAdd      string `json:"usripadd"`
 }
 
+type SignoutContent struct {
+	Username string `json:"username"`
+	UsrIpAdd string `json:"usripadd"`
+}
+
 type StateQueryContent struct {
 	OnlineState   string `json:"useronlinestate"`
 	UserName      string `json:"username"`
@@ -32,3 +39,27 @@ type StateQueryContent struct {
 	TotalTimeSpan string `json:"totaltimespan"`
 	UsrIpAdd      string `json:"useripadd"`
 }
+
+func (c SigninContent) ParsedBalance() (Money, error) {
+	return ParseMoney(c.Balance)
+}
+
+func (c SigninContent) ParsedDuration() (time.Duration, error) {
+	return ParseTimespan(c.Duration)
+}
+
+func (c SigninContent) ParsedTotalTimespan() (time.Duration, error) {
+	return ParseTimespan(c.TotalTimespan)
+}
+
+func (c StateQueryContent) ParsedBalance() (Money, error) {
+	return ParseMoney(c.Balance)
+}
+
+func (c StateQueryContent) ParsedDuration() (time.Duration, error) {
+	return ParseTimespan(c.Duration)
+}
+
+func (c StateQueryContent) ParsedTotalTimespan() (time.Duration, error) {
+	return ParseTimespan(c.TotalTimeSpan)
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"nuist_rover/nuistnet/encryption"
 	"nuist_rover/nuistnet/isp"
 	"strconv"
@@ -15,10 +16,16 @@ type NuistNetSignReq struct {
 	UsrIpAdd    string `json:"usripadd"`
 }
 
-func GetSignReqModel(account Account, ispMapping map[isp.Type]int) NuistNetSignReq {
-	base := GetSignReqModelBase(account)
-	base.Channel = strconv.Itoa(ispMapping[account.Isp])
-	return base
+// GetSignReqModel signs in through the channel of the first preferred ISP the portal lists
+func GetSignReqModel(account Account, ispMapping map[isp.Type]int) (NuistNetSignReq, error) {
+	for _, preferred := range account.Isp {
+		if channel, ok := ispMapping[preferred]; ok && preferred != isp.UNKNOWN {
+			base := GetSignReqModelBase(account)
+			base.Channel = strconv.Itoa(channel)
+			return base, nil
+		}
+	}
+	return NuistNetSignReq{}, &IspUnavailableError{Preferred: account.Isp, Listed: ispMapping}
 }
 
 func GetSignReqModelBase(account Account) NuistNetSignReq {
@@ -49,3 +56,35 @@ func (req NuistNetSignReq) Encrypt() NuistNetSignReq {
 		UsrIpAdd:    encUsrIpAdd,
 	}
 }
+
+// Decrypt reverses Encrypt, deriving the field key from the decrypted username
+func (req NuistNetSignReq) Decrypt() (NuistNetSignReq, error) {
+	username, err := encryption.Decrypt(encryption.ENCRYPTION_KEY, req.Username)
+	if err != nil {
+		return NuistNetSignReq{}, fmt.Errorf("cannot decrypt username: %s", err)
+	}
+	return req.DecryptWithKey(username, encryption.GenerateEncryptionKey(username))
+}
+
+// DecryptWithKey decrypts every field but the username, which is taken as given
+func (req NuistNetSignReq) DecryptWithKey(username string, key string) (NuistNetSignReq, error) {
+	decrypted := NuistNetSignReq{Username: username}
+	var err error
+	for _, field := range []struct {
+		name string
+		src  string
+		dst  *string
+	}{
+		{"password", req.Password, &decrypted.Password},
+		{"ifautologin", req.IfAutoLogin, &decrypted.IfAutoLogin},
+		{"channel", req.Channel, &decrypted.Channel},
+		{"pagesign", req.Pagesign, &decrypted.Pagesign},
+		{"usripadd", req.UsrIpAdd, &decrypted.UsrIpAdd},
+	} {
+		*field.dst, err = encryption.Decrypt(key, field.src)
+		if err != nil {
+			return NuistNetSignReq{}, fmt.Errorf("cannot decrypt %s: %s", field.name, err)
+		}
+	}
+	return decrypted, nil
+}
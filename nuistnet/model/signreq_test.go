@@ -0,0 +1,64 @@
+package model
+
+import (
+	"nuist_rover/nuistnet/encryption"
+	"testing"
+)
+
+func TestSignReqRoundTrip(t *testing.T) {
+	req := NuistNetSignReq{
+		Username:    "20231234567",
+		Password:    "p@ss word 密码",
+		IfAutoLogin: "0",
+		Channel:     "2",
+		Pagesign:    "secondauth",
+		UsrIpAdd:    "10.255.1.23",
+	}
+
+	encrypted := req.Encrypt()
+	if encrypted.Username == req.Username || encrypted.Password == req.Password {
+		t.Fatalf("Encrypt left fields in plaintext: %+v", encrypted)
+	}
+	// the username is always encrypted with the fixed key
+	if encrypted.Username != "5a59d23c7619eb041424c24de74ec3fa" {
+		t.Errorf("encrypted username = %s, want 5a59d23c7619eb041424c24de74ec3fa", encrypted.Username)
+	}
+
+	decrypted, err := encrypted.Decrypt()
+	if err != nil {
+		t.Fatalf("Decrypt returned error: %s", err)
+	}
+	if decrypted != req {
+		t.Errorf("Decrypt(Encrypt(req)) = %+v, want %+v", decrypted, req)
+	}
+
+	withKey, err := encrypted.DecryptWithKey(req.Username, encryption.GenerateEncryptionKey(req.Username))
+	if err != nil {
+		t.Fatalf("DecryptWithKey returned error: %s", err)
+	}
+	if withKey != req {
+		t.Errorf("DecryptWithKey = %+v, want %+v", withKey, req)
+	}
+}
+
+func TestSignReqDecryptWrongKey(t *testing.T) {
+	encrypted := NuistNetSignReq{Username: "20231234567", Password: "secret", Pagesign: "secondauth"}.Encrypt()
+
+	// another user's key either fails the padding check or yields different plaintext
+	decrypted, err := encrypted.DecryptWithKey("20231234567", encryption.GenerateEncryptionKey("20239999999"))
+	if err == nil && decrypted.Password == "secret" {
+		t.Error("DecryptWithKey with the wrong key recovered the password")
+	}
+}
+
+func TestStateQueryReqRoundTrip(t *testing.T) {
+	req := NusitNetOnlineStateQueryReq{GetUserOnlineState: "on_or_off", UsrIpAdd: "10.255.1.23"}
+
+	decrypted, err := req.Encrypt().Decrypt()
+	if err != nil {
+		t.Fatalf("Decrypt returned error: %s", err)
+	}
+	if decrypted != req {
+		t.Errorf("Decrypt(Encrypt(req)) = %+v, want %+v", decrypted, req)
+	}
+}
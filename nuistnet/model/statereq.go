@@ -1,6 +1,9 @@
 package model
 
-import "nuist_rover/nuistnet/encryption"
+import (
+	"fmt"
+	"nuist_rover/nuistnet/encryption"
+)
 
 type NusitNetOnlineStateQueryReq struct {
 	GetUserOnlineState string `json:"getuseronlinestate"`
@@ -15,3 +18,15 @@ func (req NusitNetOnlineStateQueryReq) Encrypt() NusitNetOnlineStateQueryReq {
 		UsrIpAdd:           encIp,
 	}
 }
+
+func (req NusitNetOnlineStateQueryReq) Decrypt() (NusitNetOnlineStateQueryReq, error) {
+	state, err := encryption.Decrypt(encryption.ENCRYPTION_KEY, req.GetUserOnlineState)
+	if err != nil {
+		return NusitNetOnlineStateQueryReq{}, fmt.Errorf("cannot decrypt getuseronlinestate: %s", err)
+	}
+	ip, err := encryption.Decrypt(encryption.ENCRYPTION_KEY, req.UsrIpAdd)
+	if err != nil {
+		return NusitNetOnlineStateQueryReq{}, fmt.Errorf("cannot decrypt user_ipadress: %s", err)
+	}
+	return NusitNetOnlineStateQueryReq{GetUserOnlineState: state, UsrIpAdd: ip}, nil
+}
@@ -8,88 +8,64 @@ import (
 	"io"
 	"net"
 	"net/http"
-	"nuist_rover/nuistnet/helper"
 	"nuist_rover/nuistnet/isp"
 	"nuist_rover/nuistnet/model"
-	"slices"
-	"strconv"
+	"nuist_rover/nuistnet/trace"
 	"sync"
+	"time"
 )
 
+// requestPair is a request model both before and after encryption
+type requestPair struct {
+	plain     any
+	encrypted any
+}
+
 func (c Client) GetIspMapping(account model.Account) (map[isp.Type]int, error) {
-	req := model.GetSignReqModelBase(account)
-	req.Channel = "_GET"
-	req.Pagesign = "firstauth"
-	acceptableHttpCode := []int{200, 201, 202}
+	return c.GetIspMappingWithContext(account, context.TODO())
+}
 
+func (c Client) GetIspMappingWithContext(account model.Account, ctx context.Context) (map[isp.Type]int, error) {
 	type Result struct {
 		addr    net.Addr
 		mapping map[isp.Type]int
 		err     error
 	}
 	complete := make(chan Result, len(c.clients))
-	ctx, cancelCtx := context.WithCancel(context.Background())
+	ctx, cancelCtx := context.WithCancel(ctx)
+	defer cancelCtx()
 
+	endpoint := loginApiV1(c.ServerUrl)
 	for addr, client := range c.clients {
 		go func() {
+			req := model.GetSignReqModelBase(account)
+			req.Channel = "_GET"
+			req.Pagesign = "firstauth"
 			req.UsrIpAdd = addr.(*net.TCPAddr).IP.String()
-			body, err := json.Marshal(req.Encrypt())
-			if err != nil {
-				complete <- Result{addr, nil, err}
-				return
-			}
-
-			httpReq, err := http.NewRequestWithContext(ctx, "POST", loginApiV1(c.ServerUrl), bytes.NewBuffer(body))
-			if err != nil {
-				complete <- Result{addr, nil, err}
-				return
-			}
-
-			httpReq.Header["Content-Type"] = []string{"application/json"}
-			response, err := client.Do(httpReq)
+			body, err := c.post(ctx, addr, client, endpoint, requestPair{req, req.Encrypt()})
 			if err != nil {
 				complete <- Result{addr, nil, err}
 				return
 			}
-
-			jsonDecoder := json.NewDecoder(helper.GetBody(response))
-			var responseBody model.Response[model.ListChannelsContent]
-			err = jsonDecoder.Decode(&responseBody)
-			if err != nil {
-				complete <- Result{addr, nil, err}
-				return
-			}
-			if !slices.Contains(acceptableHttpCode, responseBody.Code) {
-				complete <- Result{addr, nil, fmt.Errorf("inacceptable response code: %d", responseBody.Code)}
-				return
-			}
-
-			mapping := make(map[isp.Type]int, len(responseBody.Data.Channels))
-			for _, channel := range responseBody.Data.Channels {
-				id, err := strconv.Atoi(channel.Id)
-				if err != nil {
-					complete <- Result{addr, nil, fmt.Errorf("error parsing channel ID from response (raw: %s): %s", channel.Id, err)}
-					return
-				}
-				mapping[isp.Parse(channel.Name)] = id
-			}
-			complete <- Result{addr, mapping, nil}
+			mapping, err := decodeIspMapping(body)
+			complete <- Result{addr, mapping, err}
 		}()
 	}
 
-	errMap := make(map[net.Addr]error)
+	var errs []*model.NicError
 	for i := 0; i < len(c.clients); i++ {
 		result := <-complete
 		if result.err != nil {
-			errMap[result.addr] = result.err
+			errs = append(errs, c.nicError(result.addr, endpoint, result.err))
 		} else {
-			cancelCtx()
 			return result.mapping, nil
 		}
 	}
 
-	cancelCtx()
-	return nil, model.NewAggregatedNicError(errMap)
+	if len(errs) <= 0 {
+		return nil, fmt.Errorf("no local address on %s", c.NicInterface.Name)
+	}
+	return nil, model.NewAggregatedNicError(errs)
 }
 
 func (c Client) Signin(account model.Account) (map[net.Addr]model.SigninContent, error) {
@@ -97,99 +73,180 @@ func (c Client) Signin(account model.Account) (map[net.Addr]model.SigninContent,
 }
 
 func (c Client) SigninWithContext(account model.Account, ctx context.Context) (map[net.Addr]model.SigninContent, error) {
-	ispMapping, err := c.GetIspMapping(account)
+	ispMapping, err := c.GetIspMappingWithContext(account, ctx)
+	if err != nil {
+		return nil, err
+	}
+	base, err := model.GetSignReqModel(account, ispMapping)
 	if err != nil {
 		return nil, err
 	}
 
-	return multicastRequestFull[model.SigninContent](func(addr net.Addr, client http.Client) any {
-		req := model.GetSignReqModel(account, ispMapping)
+	return multicastRequestFull[model.SigninContent](c, func(addr net.Addr, client http.Client) requestPair {
+		req := base
 		req.Pagesign = "secondauth"
 		req.UsrIpAdd = addr.(*net.TCPAddr).IP.String()
-		return req.Encrypt()
-	}, loginApiV1(c.ServerUrl), c.clients, ctx)
+		return requestPair{req, req.Encrypt()}
+	}, loginApiV1(c.ServerUrl), ctx)
+}
+
+func (c Client) Signout(account model.Account) (map[net.Addr]model.SignoutContent, error) {
+	return c.SignoutWithContext(account, context.TODO())
 }
 
+func (c Client) SignoutWithContext(account model.Account, ctx context.Context) (map[net.Addr]model.SignoutContent, error) {
+	ispMapping, err := c.GetIspMappingWithContext(account, ctx)
+	if err != nil {
+		return nil, err
+	}
+	base, err := model.GetSignReqModel(account, ispMapping)
+	if err != nil {
+		return nil, err
+	}
+
+	return multicastRequestFull[model.SignoutContent](c, func(addr net.Addr, client http.Client) requestPair {
+		req := base
+		req.Pagesign = "thirdauth"
+		req.UsrIpAdd = addr.(*net.TCPAddr).IP.String()
+		return requestPair{req, req.Encrypt()}
+	}, logoutApiV1(c.ServerUrl), ctx)
+}
+
+// IsOnline tells whether every local address of the interface is signed in, see OnlineStates
 func (c Client) IsOnline(ctx context.Context) (bool, error) {
-	data, err := multicastRequestFast[model.StateQueryContent](func(addr net.Addr, client http.Client) any {
-		return model.NusitNetOnlineStateQueryReq{
-			GetUserOnlineState: "on_or_off",
-			UsrIpAdd:           addr.(*net.TCPAddr).IP.String(),
-		}.Encrypt()
-	}, preloginApiV1(c.ServerUrl), c.clients, ctx)
-	if data != nil {
-		switch data.OnlineState {
+	families, err := c.OnlineStates(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, online := range families {
+		if !online {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// OnlineStates queries every local address of the interface and reports the online state of each
+// address family, as returned by Family. A family is online if every address of it that answered is
+// signed in, so that an address of one family answering first cannot hide the state of the other.
+// Addresses that failed to answer are left out unless none answered
+func (c Client) OnlineStates(ctx context.Context) (map[string]bool, error) {
+	states, err := c.QueryState(ctx)
+	if len(states) <= 0 {
+		if err == nil {
+			err = fmt.Errorf("no local address on %s", c.NicInterface.Name)
+		}
+		return nil, err
+	}
+	return familyStates(states)
+}
+
+func familyStates(states map[net.Addr]model.StateQueryContent) (map[string]bool, error) {
+	families := make(map[string]bool)
+	for addr, state := range states {
+		var online bool
+		switch state.OnlineState {
 		case "on":
-			return true, nil
+			online = true
 		case "off":
-			return false, nil
+			online = false
 		default:
-			return false, fmt.Errorf("responded with unknown online state %s", data.OnlineState)
+			return nil, fmt.Errorf("responded with unknown online state %s", state.OnlineState)
 		}
-	} else {
-		return false, err
+		family := Family(addr.(*net.TCPAddr).IP)
+		if known, ok := families[family]; ok {
+			online = online && known
+		}
+		families[family] = online
 	}
+	return families, nil
+}
+
+// QueryState queries the full online state of every local address of the interface
+func (c Client) QueryState(ctx context.Context) (map[net.Addr]model.StateQueryContent, error) {
+	return multicastRequestFull[model.StateQueryContent](c, stateQueryRequest, preloginApiV1(c.ServerUrl), ctx)
+}
+
+func stateQueryRequest(addr net.Addr, client http.Client) requestPair {
+	req := model.NusitNetOnlineStateQueryReq{
+		GetUserOnlineState: "on_or_off",
+		UsrIpAdd:           addr.(*net.TCPAddr).IP.String(),
+	}
+	return requestPair{req, req.Encrypt()}
 }
 
 func loginApiV1(serverUrl string) string {
 	return fmt.Sprintf("%s/api/v1/login", serverUrl)
 }
 
+func logoutApiV1(serverUrl string) string {
+	return fmt.Sprintf("%s/api/v1/logout", serverUrl)
+}
+
 func preloginApiV1(serverUrl string) string {
 	return fmt.Sprintf("%s/api/v1/pre_login", serverUrl)
 }
 
-func jsonPost[Data any](client http.Client, requestModel any, httpEndpoint string, ctx context.Context) (*Data, error) {
-	body, err := json.Marshal(requestModel)
+// post sends the encrypted request and returns the charset-decoded response body.
+// The exchange is recorded if the client is traced
+func (c Client) post(ctx context.Context, addr net.Addr, client http.Client, httpEndpoint string, req requestPair) ([]byte, error) {
+	record := trace.Record{Time: time.Now(), Endpoint: httpEndpoint, LocalAddr: addr.String()}
+	if c.Tracer != nil {
+		record.Plaintext = trace.Redact(req.plain)
+		record.Encrypted = trace.Redact(req.encrypted)
+		defer func() { c.Tracer.Trace(record) }()
+	}
+
+	body, err := json.Marshal(req.encrypted)
 	if err != nil {
 		panic(err)
 	}
 	request, err := http.NewRequestWithContext(ctx, "POST", httpEndpoint, bytes.NewBuffer(body))
 	if err != nil {
+		record.Error = err.Error()
 		return nil, err
 	}
+	request.Header.Set("Content-Type", "application/json")
 
 	response, err := client.Do(request)
 	if err != nil {
-		return nil, fmt.Errorf("could not connect to authentication server: %s", err)
-	}
-	buffer, err := io.ReadAll(helper.GetBody(response))
-	if err != nil {
-		return nil, err
+		record.Error = err.Error()
+		return nil, fmt.Errorf("%w: %w", model.ErrServerUnreachable, err)
 	}
-	var responseBodyBase model.Response[any]
-	err = json.Unmarshal(buffer, &responseBodyBase)
+	defer response.Body.Close()
+	raw, err := io.ReadAll(response.Body)
+	record.Status = response.StatusCode
+	record.Headers = response.Header
+	record.Body = raw
 	if err != nil {
-		return nil, err
+		record.Error = err.Error()
+		return nil, fmt.Errorf("%w: %w", model.ErrServerUnreachable, err)
 	}
 
-	if responseBodyBase.Code != 200 {
-		return nil, fmt.Errorf("failure response code %d from authenication server", responseBodyBase.Code)
-	}
-
-	var responseBody model.Response[Data]
-
-	err = json.Unmarshal(buffer, &responseBody)
+	decoded, err := decodeCharset(response.Header.Get("Content-Type"), raw)
 	if err != nil {
+		record.Error = err.Error()
 		return nil, err
 	}
-
-	return &responseBody.Data, nil
+	return decoded, nil
 }
 
-func multicastRequestFull[Data any](requestModel func(addr net.Addr, client http.Client) any, httpEndpoint string, clientsByAddress map[net.Addr]http.Client, ctx context.Context) (result map[net.Addr]Data, err error) {
+func multicastRequestFull[Data any](c Client, requestModel func(addr net.Addr, client http.Client) requestPair, httpEndpoint string, ctx context.Context) (result map[net.Addr]Data, err error) {
 	var wg sync.WaitGroup
-	errorMap := make(map[net.Addr]error)
+	var mutex sync.Mutex
+	var errs []*model.NicError
 	result = make(map[net.Addr]Data)
 
-	wg.Add(len(clientsByAddress))
+	wg.Add(len(c.clients))
 
-	for addr, httpClient := range clientsByAddress {
+	for addr, httpClient := range c.clients {
 		go func() {
 			defer wg.Done()
-			response, err := jsonPost[Data](httpClient, requestModel(addr, httpClient), httpEndpoint, ctx)
+			response, err := jsonPost[Data](c, addr, httpClient, requestModel(addr, httpClient), httpEndpoint, ctx)
+			mutex.Lock()
+			defer mutex.Unlock()
 			if err != nil {
-				errorMap[addr] = err
+				errs = append(errs, c.nicError(addr, httpEndpoint, err))
 			} else {
 				result[addr] = *response
 			}
@@ -197,33 +254,18 @@ func multicastRequestFull[Data any](requestModel func(addr net.Addr, client http
 	}
 
 	wg.Wait()
-	if len(errorMap) > 0 {
-		err = model.NewAggregatedNicError(errorMap)
-	}
+	err = model.NewAggregatedNicError(errs)
 	return
 }
 
-func multicastRequestFast[Data any](requestModel func(addr net.Addr, client http.Client) any, httpEndpoint string, clientsByAddress map[net.Addr]http.Client, ctx context.Context) (result *Data, err error) {
-	var wg sync.WaitGroup
-	errorMap := make(map[net.Addr]error)
-	cancelCtx, cancelFn := context.WithCancel(ctx)
+func (c Client) nicError(addr net.Addr, endpoint string, err error) *model.NicError {
+	return &model.NicError{Nic: c.NicInterface.Name, LocalAddr: addr, Endpoint: endpoint, Err: err}
+}
 
-	wg.Add(len(clientsByAddress))
-	for addr, httpClient := range clientsByAddress {
-		go func() {
-			defer wg.Done()
-			response, err := jsonPost[Data](httpClient, requestModel(addr, httpClient), httpEndpoint, cancelCtx)
-			if err != nil {
-				errorMap[addr] = err
-			} else {
-				cancelFn()
-				result = response
-			}
-		}()
+func jsonPost[Data any](c Client, addr net.Addr, client http.Client, req requestPair, httpEndpoint string, ctx context.Context) (*Data, error) {
+	body, err := c.post(ctx, addr, client, httpEndpoint, req)
+	if err != nil {
+		return nil, err
 	}
-
-	wg.Wait()
-	cancelFn()
-	err = model.NewAggregatedNicError(errorMap)
-	return
+	return decodeResponse[Data](body)
 }
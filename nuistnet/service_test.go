@@ -0,0 +1,392 @@
+package nuistnet
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net"
+	"net/http"
+	"nuist_rover/nuistnet/fakeportal"
+	"nuist_rover/nuistnet/isp"
+	"nuist_rover/nuistnet/model"
+	"nuist_rover/nuistnet/trace"
+	"slices"
+	"testing"
+)
+
+var testAccount = model.Account{
+	Username: "20231234567",
+	Password: "secret",
+	Isp:      []isp.Type{isp.MOBILE},
+}
+
+// newTestClient starts a fake portal knowing testAccount and a client sending to it from the loopback interface
+func newTestClient(t *testing.T) (*fakeportal.Portal, Client) {
+	t.Helper()
+	portal := fakeportal.New()
+	t.Cleanup(portal.Close)
+	portal.AddAccount(testAccount.Username, testAccount.Password, "12.30")
+
+	client, err := NewClient(portal.URL(), "lo")
+	if err != nil {
+		t.Skipf("loopback interface unavailable: %s", err)
+	}
+	if len(client.LocalAddrs()) <= 0 {
+		t.Skip("loopback interface has no IPv4 address")
+	}
+	return portal, client
+}
+
+func TestSigninAndSignout(t *testing.T) {
+	portal, client := newTestClient(t)
+	ctx := context.Background()
+
+	online, err := client.IsOnline(ctx)
+	if err != nil {
+		t.Fatalf("IsOnline before signin: %s", err)
+	}
+	if online {
+		t.Fatal("online before signin")
+	}
+
+	signedIn, err := client.SigninWithContext(testAccount, ctx)
+	if err != nil {
+		t.Fatalf("Signin: %s", err)
+	}
+	if len(signedIn) != len(client.LocalAddrs()) {
+		t.Fatalf("signed in on %d addresses, want %d", len(signedIn), len(client.LocalAddrs()))
+	}
+	for addr, content := range signedIn {
+		if content.Username != testAccount.Username {
+			t.Errorf("signed in as %s on %s, want %s", content.Username, addr, testAccount.Username)
+		}
+		if content.Outport != "2" {
+			t.Errorf("signed in on channel %s, want the mobile channel 2", content.Outport)
+		}
+	}
+	if username, ok := portal.OnlineUser("127.0.0.1"); !ok || username != testAccount.Username {
+		t.Errorf("portal has %q online on 127.0.0.1, want %s", username, testAccount.Username)
+	}
+
+	online, err = client.IsOnline(ctx)
+	if err != nil {
+		t.Fatalf("IsOnline after signin: %s", err)
+	}
+	if !online {
+		t.Fatal("offline after signin")
+	}
+
+	states, err := client.QueryState(ctx)
+	if err != nil {
+		t.Fatalf("QueryState: %s", err)
+	}
+	for addr, state := range states {
+		if state.OnlineState != "on" || state.UserName != testAccount.Username {
+			t.Errorf("state on %s is %s as %s, want on as %s", addr, state.OnlineState, state.UserName, testAccount.Username)
+		}
+		if balance, err := state.ParsedBalance(); err != nil || balance != 1230 {
+			t.Errorf("balance on %s is %s, %v, want 12.30", addr, balance, err)
+		}
+	}
+
+	if _, err := client.SignoutWithContext(testAccount, ctx); err != nil {
+		t.Fatalf("Signout: %s", err)
+	}
+	if _, ok := portal.OnlineUser("127.0.0.1"); ok {
+		t.Error("still online on the portal after signout")
+	}
+	online, err = client.IsOnline(ctx)
+	if err != nil {
+		t.Fatalf("IsOnline after signout: %s", err)
+	}
+	if online {
+		t.Error("online after signout")
+	}
+
+	for _, request := range portal.Requests() {
+		if request.Stage == fakeportal.SIGNIN && request.Sign.Password != testAccount.Password {
+			t.Errorf("portal decrypted password %q, want %q", request.Sign.Password, testAccount.Password)
+		}
+	}
+}
+
+func TestSigninWrongPassword(t *testing.T) {
+	_, client := newTestClient(t)
+	account := testAccount
+	account.Password = "wrong"
+
+	_, err := client.Signin(account)
+	if !errors.Is(err, model.ErrBadCredentials) {
+		t.Fatalf("Signin with wrong password returned %v, want %s", err, model.ErrBadCredentials)
+	}
+
+	var nicError *model.NicError
+	if !errors.As(err, &nicError) || nicError.Nic != "lo" {
+		t.Errorf("error %v does not name the interface lo", err)
+	}
+}
+
+func TestSigninRateLimited(t *testing.T) {
+	portal, client := newTestClient(t)
+	portal.Script(fakeportal.SIGNIN, fakeportal.RateLimited())
+
+	_, err := client.Signin(testAccount)
+	var portalError *model.PortalError
+	if !errors.As(err, &portalError) || portalError.Code != http.StatusTooManyRequests {
+		t.Fatalf("Signin while rate limited returned %v, want a portal error with code 429", err)
+	}
+	if !errors.Is(err, model.ErrRateLimited) {
+		t.Errorf("error %v is not %s", err, model.ErrRateLimited)
+	}
+
+	// the script is used up, so the next attempt goes through
+	if _, err := client.Signin(testAccount); err != nil {
+		t.Fatalf("Signin after rate limiting: %s", err)
+	}
+}
+
+func TestSigninAlreadyOnline(t *testing.T) {
+	portal, client := newTestClient(t)
+	portal.Script(fakeportal.SIGNIN, fakeportal.Reply{Code: 400, Message: "用户已在线"})
+
+	_, err := client.Signin(testAccount)
+	if !errors.Is(err, model.ErrAlreadyOnline) {
+		t.Fatalf("Signin while online returned %v, want %s", err, model.ErrAlreadyOnline)
+	}
+	if errors.Is(err, model.ErrTooManySessions) {
+		t.Errorf("error %v would fail over to another account", err)
+	}
+}
+
+func TestQueryStateGbkReply(t *testing.T) {
+	portal, client := newTestClient(t)
+	portal.Script(fakeportal.QUERY_STATE, fakeportal.Reply{
+		Code:    200,
+		Message: "成功",
+		Data: model.StateQueryContent{
+			OnlineState: "on",
+			UserName:    testAccount.Username,
+			Balance:     "8.00元",
+			Outport:     "中国移动",
+		},
+		Charset: "GBK",
+	})
+
+	states, err := client.QueryState(context.Background())
+	if err != nil {
+		t.Fatalf("QueryState with GBK reply: %s", err)
+	}
+	for addr, state := range states {
+		if state.Outport != "中国移动" {
+			t.Errorf("outport on %s decoded as %q, want 中国移动", addr, state.Outport)
+		}
+		if balance, err := state.ParsedBalance(); err != nil || balance != 800 {
+			t.Errorf("balance on %s is %s, %v, want 8.00", addr, balance, err)
+		}
+	}
+}
+
+func TestQueryStateOddContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		wantErr     bool
+	}{
+		{contentType: `application/json;charset="utf-8"`},
+		{contentType: "application/json;charset=utf-8; foo=bar"},
+		{contentType: "application/json"},
+		{contentType: "application/json;charset=no-such-charset", wantErr: true},
+		// registered with IANA but without a decoder
+		{contentType: "application/json;charset=ISO-2022-CN", wantErr: true},
+		{contentType: "application/json;charset", wantErr: true},
+	}
+
+	for _, test := range tests {
+		portal, client := newTestClient(t)
+		portal.Script(fakeportal.QUERY_STATE, fakeportal.Reply{
+			Code:        200,
+			Message:     "成功",
+			Data:        model.StateQueryContent{OnlineState: "on", UserName: testAccount.Username},
+			ContentType: test.contentType,
+		})
+
+		states, err := client.QueryState(context.Background())
+		if test.wantErr {
+			if !errors.Is(err, model.ErrMalformedResponse) {
+				t.Errorf("QueryState with Content-Type %q returned %v, want %s", test.contentType, err, model.ErrMalformedResponse)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("QueryState with Content-Type %q returned error: %s", test.contentType, err)
+			continue
+		}
+		for addr, state := range states {
+			if state.OnlineState != "on" {
+				t.Errorf("state on %s with Content-Type %q is %s, want on", addr, test.contentType, state.OnlineState)
+			}
+		}
+	}
+}
+
+func TestIsOnlineNon200Status(t *testing.T) {
+	portal, client := newTestClient(t)
+	portal.Script(fakeportal.QUERY_STATE, fakeportal.Reply{
+		Status:  http.StatusServiceUnavailable,
+		Code:    http.StatusServiceUnavailable,
+		Message: "系统维护中",
+	})
+
+	online, err := client.IsOnline(context.Background())
+	if err == nil || online {
+		t.Fatalf("IsOnline with status 503 returned %t, %v, want an error", online, err)
+	}
+	var portalError *model.PortalError
+	if !errors.As(err, &portalError) || portalError.Code != http.StatusServiceUnavailable {
+		t.Errorf("error %v is not a portal error with code 503", err)
+	}
+}
+
+func TestIsOnlineMalformedBody(t *testing.T) {
+	portal, client := newTestClient(t)
+	portal.Script(fakeportal.QUERY_STATE, fakeportal.Malformed())
+
+	_, err := client.IsOnline(context.Background())
+	if !errors.Is(err, model.ErrMalformedResponse) {
+		t.Fatalf("IsOnline with malformed body returned %v, want %s", err, model.ErrMalformedResponse)
+	}
+}
+
+func TestSigninUnreachable(t *testing.T) {
+	portal, client := newTestClient(t)
+	portal.Close()
+
+	_, err := client.Signin(testAccount)
+	if !errors.Is(err, model.ErrServerUnreachable) {
+		t.Fatalf("Signin with server down returned %v, want %s", err, model.ErrServerUnreachable)
+	}
+}
+
+func TestReplayOddContentType(t *testing.T) {
+	record := trace.Record{
+		Endpoint: "http://10.255.255.34/api/v1/pre_login",
+		Headers:  http.Header{"Content-Type": {"application/json;charset=ISO-2022-CN"}},
+		Body:     []byte(`{"code":200,"message":"ok","data":{"useronlinestate":"on"}}`),
+	}
+	if _, err := Replay(record); !errors.Is(err, model.ErrMalformedResponse) {
+		t.Errorf("Replay with unsupported charset returned %v, want %s", err, model.ErrMalformedResponse)
+	}
+
+	record.Headers.Set("Content-Type", `application/json; charset="utf-8"; foo=bar`)
+	decoded, err := Replay(record)
+	if err != nil {
+		t.Fatalf("Replay returned error: %s", err)
+	}
+	if state, ok := decoded.(*model.StateQueryContent); !ok || state.OnlineState != "on" {
+		t.Errorf("Replay decoded %+v, want online state on", decoded)
+	}
+}
+
+func TestOnlineStatesPerFamily(t *testing.T) {
+	portal, err := fakeportal.NewDualStack()
+	if err != nil {
+		t.Skipf("cannot listen on both families: %s", err)
+	}
+	t.Cleanup(portal.Close)
+	portal.AddAccount(testAccount.Username, testAccount.Password, "12.30")
+
+	dualStack, err := NewClientWithOptions(portal.URL(), "lo", ClientOptions{IPv6: true})
+	if err != nil {
+		t.Skipf("loopback interface unavailable: %s", err)
+	}
+	if _, ok := dualStack.LocalAddrOf("ipv6"); !ok {
+		t.Skip("loopback interface has no IPv6 address")
+	}
+	if ips, err := net.LookupIP("localhost"); err != nil || !slices.ContainsFunc(ips, func(ip net.IP) bool { return ip.To4() == nil }) {
+		t.Skip("localhost does not resolve to an IPv6 address")
+	}
+	ipv4Only, err := NewClient(portal.URL(), "lo")
+	if err != nil {
+		t.Fatalf("NewClient: %s", err)
+	}
+	ctx := context.Background()
+
+	// signed in over IPv4 only, so IPv6 answering first must not decide the outcome either way
+	if _, err := ipv4Only.SigninWithContext(testAccount, ctx); err != nil {
+		t.Fatalf("Signin over IPv4: %s", err)
+	}
+	for range 10 {
+		families, err := dualStack.OnlineStates(ctx)
+		if err != nil {
+			t.Fatalf("OnlineStates: %s", err)
+		}
+		if !families["ipv4"] || families["ipv6"] || len(families) != 2 {
+			t.Fatalf("OnlineStates = %v, want ipv4 online and ipv6 offline", families)
+		}
+		if online, err := dualStack.IsOnline(ctx); err != nil || online {
+			t.Fatalf("IsOnline = %t, %v, want offline as ipv6 is", online, err)
+		}
+	}
+
+	if _, err := dualStack.SigninWithContext(testAccount, ctx); err != nil {
+		t.Fatalf("Signin over both families: %s", err)
+	}
+	families, err := dualStack.OnlineStates(ctx)
+	if err != nil || !families["ipv4"] || !families["ipv6"] {
+		t.Fatalf("OnlineStates = %v, %v, want both online", families, err)
+	}
+	if online, err := dualStack.IsOnline(ctx); err != nil || !online {
+		t.Fatalf("IsOnline = %t, %v, want online", online, err)
+	}
+}
+
+func TestFamilyStates(t *testing.T) {
+	ipv4 := func(last byte) net.Addr { return &net.TCPAddr{IP: net.IPv4(10, 255, 1, last)} }
+	ipv6 := func(last byte) net.Addr { return &net.TCPAddr{IP: net.IP{0x24, 0x01, 15: last}} }
+	on := model.StateQueryContent{OnlineState: "on"}
+	off := model.StateQueryContent{OnlineState: "off"}
+
+	tests := []struct {
+		name    string
+		states  map[net.Addr]model.StateQueryContent
+		want    map[string]bool
+		wantErr bool
+	}{
+		{
+			name:   "ipv6 off does not hide ipv4 on",
+			states: map[net.Addr]model.StateQueryContent{ipv4(1): on, ipv6(1): off},
+			want:   map[string]bool{"ipv4": true, "ipv6": false},
+		},
+		{
+			name:   "ipv6 on does not hide ipv4 off",
+			states: map[net.Addr]model.StateQueryContent{ipv4(1): off, ipv6(1): on},
+			want:   map[string]bool{"ipv4": false, "ipv6": true},
+		},
+		{
+			name:   "a family is online only if all of its addresses are",
+			states: map[net.Addr]model.StateQueryContent{ipv4(1): on, ipv4(2): off, ipv6(1): on, ipv6(2): on},
+			want:   map[string]bool{"ipv4": false, "ipv6": true},
+		},
+		{
+			name:    "unknown state",
+			states:  map[net.Addr]model.StateQueryContent{ipv4(1): {OnlineState: "1"}},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		// map order varies, so each case is tried a few times
+		for range 10 {
+			got, err := familyStates(test.states)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("%s: familyStates = %v, want error", test.name, got)
+				}
+				break
+			}
+			if err != nil || !maps.Equal(got, test.want) {
+				t.Errorf("%s: familyStates = %v, %v, want %v", test.name, got, err, test.want)
+				break
+			}
+		}
+	}
+}
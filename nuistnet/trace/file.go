@@ -0,0 +1,55 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// File appends records to a JSON lines file
+type File struct {
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func Create(filename string) (*File, error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetEscapeHTML(false)
+	return &File{file: file, encoder: encoder}, nil
+}
+
+func (f *File) Trace(record Record) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	_ = f.encoder.Encode(record)
+}
+
+func (f *File) Close() error {
+	return f.file.Close()
+}
+
+// Read calls fn for each record in a JSON lines file, stopping at the first error
+func Read(reader io.Reader, fn func(record Record) error) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) <= 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
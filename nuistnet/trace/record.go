@@ -0,0 +1,48 @@
+package trace
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Record is one request to the authentication server and what came back
+type Record struct {
+	Time      time.Time       `json:"time"`
+	Endpoint  string          `json:"endpoint"`
+	LocalAddr string          `json:"local_addr"`
+	Plaintext json.RawMessage `json:"request_plaintext"`
+	Encrypted json.RawMessage `json:"request_encrypted"`
+	Status    int             `json:"status,omitempty"`
+	Headers   http.Header     `json:"headers,omitempty"`
+	// Body is the raw response body, before charset decoding
+	Body  []byte `json:"body,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Tracer receives every exchange of a traced client
+type Tracer interface {
+	Trace(record Record)
+}
+
+const redacted = "[redacted]"
+
+// Redact marshals a request model, replacing its password with a placeholder
+func Redact(requestModel any) json.RawMessage {
+	buffer, err := json.Marshal(requestModel)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]any
+	if json.Unmarshal(buffer, &fields) != nil {
+		return buffer
+	}
+	if _, ok := fields["password"]; ok {
+		fields["password"] = redacted
+	}
+	buffer, err = json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return buffer
+}
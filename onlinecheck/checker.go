@@ -0,0 +1,68 @@
+package onlinecheck
+
+import (
+	"context"
+	"fmt"
+	"nuist_rover/configuration"
+	"nuist_rover/logger"
+	"nuist_rover/nuistnet"
+	"sync"
+)
+
+// Target is the interface a checker checks
+type Target struct {
+	Nic    string
+	Client nuistnet.Client
+	Log    logger.Logger
+}
+
+// Checker tells whether an interface is online
+type Checker interface {
+	Check(ctx context.Context, target Target) (bool, error)
+}
+
+// FamilyChecker is a Checker that tells the online state of each address family at once
+type FamilyChecker interface {
+	Checker
+	CheckFamilies(ctx context.Context, target Target) (map[string]bool, error)
+}
+
+// Factory builds a checker from its configuration
+type Factory func(config configuration.Check) (Checker, error)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]Factory)
+)
+
+// Register makes a checker available as an online check method, replacing any checker of the same method
+func Register(method string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry[method] = factory
+}
+
+// New builds the checker of the configured method
+func New(config configuration.Check) (Checker, error) {
+	method := config.Method
+	if method == "" {
+		method = "portal"
+	}
+	registryMutex.RLock()
+	factory, ok := registry[method]
+	registryMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown online check method: %s", method)
+	}
+	return factory(config)
+}
+
+func init() {
+	Register("portal", newPortalChecker)
+	Register("ping", newIcmpChecker)
+	Register("icmp", newIcmpChecker)
+	Register("http", newHttpChecker)
+	Register("captive", newHttpChecker)
+	Register("tcp", newTcpChecker)
+	Register("dns", newDnsChecker)
+}
@@ -0,0 +1,58 @@
+package onlinecheck
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"nuist_rover/configuration"
+)
+
+// defaultDnsServer is asked unless a server is configured. The system resolver won't do,
+// as on OpenWrt it is the local dnsmasq answering from its cache
+const defaultDnsServer = "1.2.4.8:53"
+
+// dnsChecker passes if host can be resolved from the interface
+type dnsChecker struct {
+	host   string
+	server string
+}
+
+func newDnsChecker(config configuration.Check) (Checker, error) {
+	checker := dnsChecker{config.Host, config.Server}
+	if checker.host == "" {
+		checker.host = "www.baidu.com"
+	}
+	if checker.server == "" {
+		checker.server = defaultDnsServer
+	} else if _, _, err := net.SplitHostPort(checker.server); err != nil {
+		checker.server = net.JoinHostPort(checker.server, "53")
+	}
+	return checker, nil
+}
+
+func (d dnsChecker) Check(ctx context.Context, target Target) (bool, error) {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			dialer, err := boundDialer(target)
+			if err != nil {
+				return nil, err
+			}
+			if network == "udp" || network == "udp4" || network == "udp6" {
+				if tcpAddr, ok := dialer.LocalAddr.(*net.TCPAddr); ok {
+					dialer.LocalAddr = &net.UDPAddr{IP: tcpAddr.IP}
+				}
+			}
+			return dialer.DialContext(ctx, network, d.server)
+		},
+	}
+	if len(target.Client.LocalAddrs()) <= 0 {
+		return false, fmt.Errorf("no address on %s", target.Nic)
+	}
+	addrs, err := resolver.LookupHost(ctx, d.host)
+	if err != nil {
+		target.Log.Log("dns check on %s cannot resolve %s: %s", target.Nic, d.host, err)
+		return false, nil
+	}
+	return len(addrs) > 0, nil
+}
@@ -0,0 +1,118 @@
+package onlinecheck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"nuist_rover/configuration"
+	"regexp"
+)
+
+const defaultProbeUrl = "http://connect.rom.miui.com/generate_204"
+
+// httpChecker fetches a probe url through the interface and passes if it is answered with 204,
+// or with a body containing the expected piece. Anything else, most notably a redirect,
+// means a captive portal intercepts the request
+type httpChecker struct {
+	url    string
+	expect string
+	family string
+}
+
+func newHttpChecker(config configuration.Check) (Checker, error) {
+	checker := httpChecker{config.Url, config.Expect, familyOf(config)}
+	if checker.url == "" {
+		checker.url = defaultProbeUrl
+	}
+	return checker, nil
+}
+
+func (h httpChecker) Check(ctx context.Context, target Target) (bool, error) {
+	addr, ok := target.Client.LocalAddrOf(h.family)
+	if !ok {
+		return false, fmt.Errorf("no %s address on %s", h.family, target.Nic)
+	}
+	client, _ := target.Client.HttpClient(addr)
+
+	online, portal, err := DetectCaptivePortal(ctx, client, h.url, h.expect)
+	if err != nil {
+		return false, err
+	}
+	if !online {
+		if len(portal) <= 0 {
+			portal = "an unknown page"
+		} else if isAuthenticationServer(portal, target.Client.ServerUrl) {
+			portal += " (the authentication server)"
+		}
+		target.Log.Info("captive portal on %s redirects to %s", target.Nic, portal)
+	}
+	return online, nil
+}
+
+// DetectCaptivePortal fetches probeUrl without following redirects. It is online if the probe is
+// answered with 204, or with a body containing expect. Otherwise the url of the captive portal is
+// returned if it can be told from the redirect or the page
+func DetectCaptivePortal(ctx context.Context, client http.Client, probeUrl string, expect string) (bool, string, error) {
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	request, err := http.NewRequestWithContext(ctx, "GET", probeUrl, nil)
+	if err != nil {
+		return false, "", err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return false, "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 && response.StatusCode < 400 {
+		location, err := response.Location()
+		if errors.Is(err, http.ErrNoLocation) {
+			return false, "", nil
+		}
+		if err != nil {
+			return false, "", err
+		}
+		return false, location.String(), nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+	if err != nil {
+		return false, "", err
+	}
+	if expect == "" && response.StatusCode == http.StatusNoContent ||
+		expect != "" && response.StatusCode == http.StatusOK && bytes.Contains(body, []byte(expect)) {
+		return true, "", nil
+	}
+	return false, portalInPage(body, request.URL), nil
+}
+
+var (
+	// redirectPattern finds meta refresh and script redirects
+	redirectPattern = regexp.MustCompile(`(?i)(?:url\s*=|location(?:\.href)?\s*=|location\.replace\()\s*['"]?([^'"\s<>)]+)`)
+	urlPattern      = regexp.MustCompile(`https?://[^'"\s<>]+`)
+)
+
+// portalInPage looks for where an intercepted page sends the browser to
+func portalInPage(body []byte, base *url.URL) string {
+	if match := redirectPattern.FindSubmatch(body); match != nil {
+		if resolved, err := base.Parse(string(match[1])); err == nil {
+			return resolved.String()
+		}
+	}
+	return string(urlPattern.Find(body))
+}
+
+func isAuthenticationServer(portal string, serverUrl string) bool {
+	portalUrl, err := url.Parse(portal)
+	if err != nil {
+		return false
+	}
+	server, err := url.Parse(serverUrl)
+	return err == nil && len(server.Host) > 0 && portalUrl.Hostname() == server.Hostname()
+}
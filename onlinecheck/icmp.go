@@ -0,0 +1,166 @@
+package onlinecheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"math/rand/v2"
+	"net"
+	"nuist_rover/configuration"
+	"os"
+	"time"
+)
+
+// icmpChecker pings a host from the interface and passes if enough echoes are replied.
+// Raw sockets need root privileges, without which unprivileged ICMP datagram sockets are used,
+// provided net.ipv4.ping_group_range allows them
+type icmpChecker struct {
+	host      string
+	count     int
+	threshold float64
+	family    string
+}
+
+func newIcmpChecker(config configuration.Check) (Checker, error) {
+	checker := icmpChecker{config.Host, config.Count, config.Threshold, familyOf(config)}
+	if checker.host == "" && checker.family == "ipv6" {
+		checker.host = "2400:3200::1"
+	} else if checker.host == "" {
+		checker.host = "8.8.8.8"
+	}
+	if checker.count <= 0 {
+		checker.count = 3
+	}
+	if checker.threshold <= 0 {
+		checker.threshold = 0.5
+	}
+	return checker, nil
+}
+
+func (p icmpChecker) Check(ctx context.Context, target Target) (bool, error) {
+	addr, ok := target.Client.LocalAddrOf(p.family)
+	if !ok {
+		return false, fmt.Errorf("no %s address on %s", p.family, target.Nic)
+	}
+	network := "ip4"
+	if p.family == "ipv6" {
+		network = "ip6"
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, network, p.host)
+	if err != nil {
+		return false, err
+	}
+
+	conn, err := listenIcmp(addr.IP)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	replied := 0
+	for seq := 0; seq < p.count && ctx.Err() == nil; seq++ {
+		ok, err := conn.echo(ctx, ips[0], seq)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			replied++
+		}
+	}
+
+	successRate := float64(replied) / float64(p.count)
+	return successRate >= p.threshold, nil
+}
+
+// icmpConn is an ICMP or ICMPv6 socket bound to one local address
+type icmpConn struct {
+	*icmp.PacketConn
+	v6 bool
+	// datagram is set for unprivileged sockets, whose echo ID is the local port chosen by the kernel
+	datagram bool
+	id       int
+}
+
+func listenIcmp(local net.IP) (*icmpConn, error) {
+	v6 := local.To4() == nil
+	rawNetwork, datagramNetwork := "ip4:icmp", "udp4"
+	if v6 {
+		rawNetwork, datagramNetwork = "ip6:ipv6-icmp", "udp6"
+	}
+
+	conn, err := icmp.ListenPacket(rawNetwork, local.String())
+	if err == nil {
+		return &icmpConn{PacketConn: conn, v6: v6, id: rand.IntN(0xffff) + 1}, nil
+	}
+	if !errors.Is(err, os.ErrPermission) {
+		return nil, err
+	}
+
+	conn, datagramErr := icmp.ListenPacket(datagramNetwork, local.String())
+	if datagramErr != nil {
+		return nil, fmt.Errorf("cannot open raw icmp socket: %s, nor an unprivileged one: %s", err, datagramErr)
+	}
+	return &icmpConn{PacketConn: conn, v6: v6, datagram: true, id: conn.LocalAddr().(*net.UDPAddr).Port}, nil
+}
+
+// echo sends one echo request and waits up to a second for its reply. Replies of other
+// checks, which may be running on other interfaces at the same time, are told apart by ID and sequence
+func (c *icmpConn) echo(ctx context.Context, dst net.IP, seq int) (bool, error) {
+	var echoType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if c.v6 {
+		echoType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+	request := icmp.Message{
+		Type: echoType, Code: 0,
+		Body: &icmp.Echo{ID: c.id, Seq: seq, Data: []byte("nuistrover")},
+	}
+	wb, err := request.Marshal(nil)
+	if err != nil {
+		return false, err
+	}
+	var dstAddr net.Addr = &net.IPAddr{IP: dst}
+	if c.datagram {
+		dstAddr = &net.UDPAddr{IP: dst}
+	}
+	if _, err := c.WriteTo(wb, dstAddr); err != nil {
+		return false, err
+	}
+
+	deadline := time.Now().Add(1 * time.Second)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	_ = c.SetReadDeadline(deadline)
+	rb := make([]byte, 1500)
+	for {
+		n, peer, err := c.ReadFrom(rb)
+		if err != nil {
+			return false, nil // timeout or error, consider failed
+		}
+		if !peerIP(peer).Equal(dst) {
+			continue
+		}
+		reply, err := icmp.ParseMessage(replyType.Protocol(), rb[:n])
+		if err != nil || reply.Type != replyType {
+			continue
+		}
+		echo, ok := reply.Body.(*icmp.Echo)
+		if ok && echo.ID == c.id && echo.Seq == seq {
+			return true, nil
+		}
+	}
+}
+
+func peerIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.IPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	default:
+		return nil
+	}
+}
@@ -2,110 +2,159 @@ package onlinecheck
 
 import (
 	"context"
-	"golang.org/x/net/icmp"
-	"golang.org/x/net/ipv4"
-	"net"
+	"errors"
+	"fmt"
+	"maps"
 	"nuist_rover/configuration"
 	"nuist_rover/logger"
 	"nuist_rover/nuistnet"
-	"os"
+	"slices"
+	"sync"
 	"time"
 )
 
-// CheckOnline performs online check based on configuration
-func CheckOnline(ctx context.Context, config configuration.Root, nic string, client nuistnet.Client, log logger.Logger) (bool, error) {
+// checkTimeout bounds every single check
+const checkTimeout = 10 * time.Second
+
+// CheckOnline performs online check based on configuration. Besides the overall outcome it returns
+// the online state of each address family that was checked, which is nil if no check was run
+func CheckOnline(ctx context.Context, config configuration.Root, nic string, client nuistnet.Client, log logger.Logger) (bool, map[string]bool, error) {
 	if !config.OnlineCheck.Enabled {
-		return false, nil // not enabled, proceed with signin
+		return false, nil, nil // not enabled, proceed with signin
 	}
 
-	method := config.OnlineCheck.Method
-	if method == "" {
-		method = "portal"
+	checks := config.OnlineCheck.ChecksOf(nic)
+	if len(checks) <= 0 {
+		return false, nil, nil
 	}
-
-	switch method {
-	case "portal":
-		return checkOnlineViaPortal(ctx, client, log)
-	case "ping":
-		host := config.OnlineCheck.Host
-		if host == "" {
-			host = "8.8.8.8"
+	checkers := make([]Checker, len(checks))
+	for i, check := range checks {
+		checker, err := New(check)
+		if err != nil {
+			return false, nil, err
 		}
-		count := config.OnlineCheck.Count
-		if count <= 0 {
-			count = 3
+		checkers[i] = checker
+	}
+
+	required, err := requiredPasses(config.OnlineCheck, len(checkers))
+	if err != nil {
+		return false, nil, err
+	}
+	results := runAll(ctx, checkers, Target{Nic: nic, Client: client, Log: log})
+	families := familyStates(checks, results)
+	logFamilies(log, nic, families)
+
+	passed, told := 0, 0
+	var problems []error
+	for _, result := range results {
+		if result.err != nil {
+			problems = append(problems, result.err)
+			continue
 		}
-		threshold := config.OnlineCheck.Threshold
-		if threshold <= 0 {
-			threshold = 0.5
+		told++
+		if result.online {
+			passed++
 		}
-		return checkOnlineViaPing(host, count, threshold, log)
-	default:
-		log.Warning("unknown online check method: %s", method)
-		return false, nil
 	}
+	if told <= 0 {
+		return false, families, errors.Join(problems...)
+	}
+	for _, problem := range problems {
+		log.Warning("online check on %s failed: %s", nic, problem)
+	}
+	return passed >= required, families, nil
 }
 
-func checkOnlineViaPortal(ctx context.Context, client nuistnet.Client, log logger.Logger) (bool, error) {
-	onlineCheckCtx, cancelOnlineCheckCtx := context.WithTimeout(ctx, 10*time.Second)
-	defer cancelOnlineCheckCtx()
+type result struct {
+	online bool
+	// families is set by checkers telling each address family apart
+	families map[string]bool
+	err      error
+}
 
-	signedIn, err := client.IsOnline(onlineCheckCtx)
-	if err != nil {
-		log.Warning("cannot query dial state via portal: %s", err)
-		return false, err
+// runAll runs the checkers at once, returning their results in the same order
+func runAll(ctx context.Context, checkers []Checker, target Target) []result {
+	var wg sync.WaitGroup
+	results := make([]result, len(checkers))
+	wg.Add(len(checkers))
+	for i, checker := range checkers {
+		go func() {
+			defer wg.Done()
+			checkCtx, cancelCheckCtx := context.WithTimeout(ctx, checkTimeout)
+			defer cancelCheckCtx()
+			if familyChecker, ok := checker.(FamilyChecker); ok {
+				families, err := familyChecker.CheckFamilies(checkCtx, target)
+				online := err == nil && len(families) > 0
+				for _, familyOnline := range families {
+					online = online && familyOnline
+				}
+				results[i] = result{online, families, err}
+				return
+			}
+			online, err := checker.Check(checkCtx, target)
+			results[i] = result{online, nil, err}
+		}()
 	}
-	return signedIn, nil
+	wg.Wait()
+	return results
 }
 
-func checkOnlineViaPing(host string, count int, threshold float64, log logger.Logger) (bool, error) {
-	c, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
-	if err != nil {
-		return false, err
+// familyStates combines the results into the online state of each address family,
+// which is online if any check of it passed
+func familyStates(checks []configuration.Check, results []result) map[string]bool {
+	online := make(map[string]bool)
+	observe := func(family string, passed bool) {
+		online[family] = online[family] || passed
 	}
-	defer c.Close()
-
-	successCount := 0
-	for i := 0; i < count; i++ {
-		wm := icmp.Message{
-			Type: ipv4.ICMPTypeEcho, Code: 0,
-			Body: &icmp.Echo{
-				ID:   os.Getpid() & 0xffff,
-				Seq:  i,
-				Data: []byte("ping"),
-			},
-		}
-		wb, err := wm.Marshal(nil)
-		if err != nil {
-			return false, err
-		}
-
-		dst, err := net.ResolveIPAddr("ip4", host)
-		if err != nil {
-			return false, err
-		}
-
-		if _, err := c.WriteTo(wb, dst); err != nil {
-			return false, err
+	for i, check := range checks {
+		switch {
+		case results[i].err != nil:
+			observe(familyOf(check), false)
+		case results[i].families != nil:
+			for family, passed := range results[i].families {
+				observe(family, passed)
+			}
+		default:
+			observe(familyOf(check), results[i].online)
 		}
+	}
+	return online
+}
 
-		rb := make([]byte, 1500)
-		c.SetReadDeadline(time.Now().Add(1 * time.Second))
-		n, _, err := c.ReadFrom(rb)
-		if err != nil {
-			continue // timeout or error, consider failed
+// logFamilies reports the online state of each address family separately, if more than one was checked
+func logFamilies(log logger.Logger, nic string, online map[string]bool) {
+	if len(online) <= 1 {
+		return
+	}
+	for _, family := range slices.Sorted(maps.Keys(online)) {
+		state := "offline"
+		if online[family] {
+			state = "online"
 		}
+		log.With(logger.FAMILY, family).Info("%s is %s over %s", nic, state, family)
+	}
+}
 
-		rm, err := icmp.ParseMessage(1, rb[:n])
-		if err != nil {
-			continue
-		}
+func familyOf(check configuration.Check) string {
+	if check.Family == "" {
+		return "ipv4"
+	}
+	return check.Family
+}
 
-		if rm.Type == ipv4.ICMPTypeEchoReply {
-			successCount++
+// requiredPasses is how many of total checks must pass for the mode of config
+func requiredPasses(config configuration.OnlineCheck, total int) (int, error) {
+	switch config.Mode {
+	case "", "any":
+		return 1, nil
+	case "all":
+		return total, nil
+	case "quorum":
+		if config.Quorum > 0 {
+			return min(config.Quorum, total), nil
 		}
+		return total/2 + 1, nil
+	default:
+		return 0, fmt.Errorf("unknown online check mode: %s", config.Mode)
 	}
-
-	successRate := float64(successCount) / float64(count)
-	return successRate >= threshold, nil
-}
\ No newline at end of file
+}
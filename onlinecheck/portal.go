@@ -0,0 +1,31 @@
+package onlinecheck
+
+import (
+	"context"
+	"nuist_rover/configuration"
+)
+
+// portalChecker asks the authentication server whether the interface is signed in
+type portalChecker struct{}
+
+func newPortalChecker(configuration.Check) (Checker, error) {
+	return portalChecker{}, nil
+}
+
+func (portalChecker) Check(ctx context.Context, target Target) (bool, error) {
+	signedIn, err := target.Client.IsOnline(ctx)
+	if err != nil {
+		target.Log.Warning("cannot query dial state via portal: %s", err)
+		return false, err
+	}
+	return signedIn, nil
+}
+
+func (portalChecker) CheckFamilies(ctx context.Context, target Target) (map[string]bool, error) {
+	families, err := target.Client.OnlineStates(ctx)
+	if err != nil {
+		target.Log.Warning("cannot query dial state via portal: %s", err)
+		return nil, err
+	}
+	return families, nil
+}
@@ -0,0 +1,46 @@
+package onlinecheck
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"nuist_rover/configuration"
+)
+
+// tcpChecker passes if a connection to host:port can be established from the interface
+type tcpChecker struct {
+	address string
+}
+
+func newTcpChecker(config configuration.Check) (Checker, error) {
+	checker := tcpChecker{config.Host}
+	if checker.address == "" {
+		checker.address = "1.2.4.8:53"
+	}
+	return checker, nil
+}
+
+func (t tcpChecker) Check(ctx context.Context, target Target) (bool, error) {
+	dialer, err := boundDialer(target)
+	if err != nil {
+		return false, err
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", t.address)
+	if err != nil {
+		// refused or unreachable, which is what being offline looks like
+		target.Log.Log("tcp check on %s cannot connect to %s: %s", target.Nic, t.address, err)
+		return false, nil
+	}
+	_ = conn.Close()
+	return true, nil
+}
+
+// boundDialer dials from the first local address of the interface. Without one it fails rather than
+// dialing over the default route, which may pass the check through another uplink
+func boundDialer(target Target) (*net.Dialer, error) {
+	addrs := target.Client.LocalAddrs()
+	if len(addrs) <= 0 {
+		return nil, fmt.Errorf("no address on %s", target.Nic)
+	}
+	return &net.Dialer{LocalAddr: addrs[0]}, nil
+}
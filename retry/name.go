@@ -0,0 +1,12 @@
+package retry
+
+func (k Kind) Name() string {
+	switch k {
+	case LINEAR:
+		return "linear"
+	case EXPONENTIAL:
+		return "exponential"
+	default:
+		return "fixed"
+	}
+}
@@ -0,0 +1,13 @@
+package retry
+
+// ParseKind returns FIXED for empty or unknown names
+func ParseKind(name string) Kind {
+	switch name {
+	case "linear":
+		return LINEAR
+	case "exponential":
+		return EXPONENTIAL
+	default:
+		return FIXED
+	}
+}
@@ -0,0 +1,68 @@
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+type Kind int
+
+const (
+	FIXED Kind = iota
+	LINEAR
+	EXPONENTIAL
+)
+
+// Policy decides how long to wait before the next attempt
+type Policy struct {
+	Kind     Kind
+	Interval time.Duration
+	// Max caps the delay before jitter is applied, zero means no cap
+	Max time.Duration
+	// Jitter randomizes each delay by up to this fraction of it, in either direction
+	Jitter float64
+}
+
+// Delay returns the wait before the attempt following the given number of failed ones
+func (p Policy) Delay(failures int) time.Duration {
+	failures = max(failures, 1)
+	var delay time.Duration
+	switch p.Kind {
+	case LINEAR:
+		delay = p.Interval * time.Duration(failures)
+	case EXPONENTIAL:
+		delay = p.Interval
+		for i := 1; i < failures && (p.Max <= 0 || delay < p.Max); i++ {
+			delay *= 2
+		}
+	default:
+		delay = p.Interval
+	}
+	if p.Max > 0 {
+		delay = min(delay, p.Max)
+	}
+
+	if p.Jitter > 0 {
+		jitter := min(p.Jitter, 1)
+		delay += time.Duration((rand.Float64()*2 - 1) * jitter * float64(delay))
+	}
+	return max(delay, 0)
+}
+
+// Wait sleeps for Delay(failures), returning early with the context's error if it is done
+func (p Policy) Wait(ctx context.Context, failures int) error {
+	return Sleep(ctx, p.Delay(failures))
+}
+
+// Sleep is time.Sleep that respects context cancellation
+func Sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
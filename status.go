@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"nuist_rover/configuration"
+	"nuist_rover/nuistnet"
+	"nuist_rover/nuistnet/model"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type stateReport struct {
+	Nic           string `json:"nic"`
+	LocalAddr     string `json:"local_addr,omitempty"`
+	Family        string `json:"family,omitempty"`
+	Online        bool   `json:"online"`
+	Username      string `json:"username,omitempty"`
+	Balance       string `json:"balance,omitempty"`
+	Duration      string `json:"duration,omitempty"`
+	Outport       string `json:"outport,omitempty"`
+	TotalTimespan string `json:"total_timespan,omitempty"`
+	UsrIpAdd      string `json:"ip,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+func newStateReport(nic string, addr net.Addr, state model.StateQueryContent) stateReport {
+	return stateReport{
+		Nic:           nic,
+		LocalAddr:     addr.String(),
+		Family:        nuistnet.Family(addr.(*net.TCPAddr).IP),
+		Online:        state.OnlineState == "on",
+		Username:      state.UserName,
+		Balance:       state.Balance,
+		Duration:      state.Duration,
+		Outport:       state.Outport,
+		TotalTimespan: state.TotalTimeSpan,
+		UsrIpAdd:      state.UsrIpAdd,
+	}
+}
+
+func query_all_parallel(ctx context.Context, accounts map[string][]model.Account, config configuration.Root) []stateReport {
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var reports []stateReport
+	wg.Add(len(accounts))
+	for nic := range accounts {
+		go func() {
+			defer wg.Done()
+			nicReports := query(ctx, nic, config)
+			mutex.Lock()
+			reports = append(reports, nicReports...)
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	slices.SortFunc(reports, func(a, b stateReport) int {
+		if c := strings.Compare(a.Nic, b.Nic); c != 0 {
+			return c
+		}
+		return strings.Compare(a.LocalAddr, b.LocalAddr)
+	})
+	return reports
+}
+
+func query(ctx context.Context, nic string, config configuration.Root) []stateReport {
+	client, err := newClient(config, nic)
+	if err != nil {
+		return []stateReport{{Nic: nic, Error: err.Error()}}
+	}
+
+	queryCtx, cancelQueryCtx := context.WithTimeout(ctx, 10*time.Second)
+	defer cancelQueryCtx()
+	states, err := client.QueryState(queryCtx)
+
+	var reports []stateReport
+	for addr, state := range states {
+		reports = append(reports, newStateReport(nic, addr, state))
+	}
+	var aggregated *model.AggregatedNicError
+	if errors.As(err, &aggregated) {
+		for _, nicErr := range aggregated.Errors() {
+			reports = append(reports, stateReport{
+				Nic:       nic,
+				LocalAddr: nicErr.LocalAddr.String(),
+				Family:    nuistnet.Family(nicErr.LocalAddr.(*net.TCPAddr).IP),
+				Error:     nicErr.Err.Error(),
+			})
+		}
+	} else if err != nil {
+		reports = append(reports, stateReport{Nic: nic, Error: err.Error()})
+	}
+	return reports
+}
+
+func printStateJson(w io.Writer, reports []stateReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if reports == nil {
+		reports = []stateReport{}
+	}
+	return encoder.Encode(reports)
+}
+
+func printStateTable(w io.Writer, reports []stateReport) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "NIC\tADDRESS\tFAMILY\tSTATE\tUSER\tBALANCE\tDURATION\tTOTAL\tOUTPORT\tIP")
+	for _, report := range reports {
+		state := "offline"
+		if len(report.Error) > 0 {
+			state = "error"
+		} else if report.Online {
+			state = "online"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			report.Nic, report.LocalAddr, report.Family, state, report.Username, report.Balance,
+			report.Duration, report.TotalTimespan, report.Outport, report.UsrIpAdd)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	separated := false
+	for _, report := range reports {
+		if len(report.Error) <= 0 {
+			continue
+		}
+		if !separated {
+			fmt.Fprintln(w)
+			separated = true
+		}
+		fmt.Fprintf(w, "%s %s: %s\n", report.Nic, report.LocalAddr, report.Error)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"nuist_rover/configuration"
+	"nuist_rover/nuistnet"
+	"sync"
+	"time"
+)
+
+// supervisor owns the dialing of one interface, so that it is never dialed by two rounds at once
+type supervisor struct {
+	nic      string
+	ctx      context.Context
+	cancel   context.CancelFunc
+	triggers chan chan error
+	wake     chan struct{}
+
+	mutex sync.Mutex
+	// cached is the client of the interface while its addresses stay the same
+	cached    *nuistnet.Client
+	cachedFor clientKey
+	// restartedAt is when a dial last restarted the link
+	restartedAt time.Time
+}
+
+// linkRestartGrace is how long the link coming up is taken as the result of restarting it
+const linkRestartGrace = 30 * time.Second
+
+// clientKey is the part of the configuration a client is built from
+type clientKey struct {
+	serverUrl string
+	ipv6      bool
+}
+
+func newSupervisor(ctx context.Context, nic string) *supervisor {
+	supervisorCtx, cancel := context.WithCancel(ctx)
+	return &supervisor{
+		nic:      nic,
+		ctx:      supervisorCtx,
+		cancel:   cancel,
+		triggers: make(chan chan error, 16),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// trigger asks for an immediate dial and waits for its outcome
+func (s *supervisor) trigger(ctx context.Context) error {
+	result := make(chan error, 1)
+	select {
+	case s.triggers <- result:
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	// the trigger may be queued and never served if the supervisor is stopped meanwhile
+	select {
+	case err := <-result:
+		return err
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// invalidate drops the cached client, whose local addresses are out of date
+func (s *supervisor) invalidate() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cached = nil
+}
+
+// refresh invalidates the cached client and asks for a dial without waiting for it
+func (s *supervisor) refresh() {
+	s.invalidate()
+	select {
+	case s.triggers <- make(chan error, 1):
+	default:
+	}
+}
+
+func (s *supervisor) linkRestarted() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.restartedAt = time.Now()
+}
+
+// restartedRecently tells whether the link coming up now is likely due to a dial restarting it
+func (s *supervisor) restartedRecently() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return !s.restartedAt.IsZero() && time.Since(s.restartedAt) < linkRestartGrace
+}
+
+// client returns the cached client of the interface, building it first if there is none
+func (s *supervisor) client(config configuration.Root) (nuistnet.Client, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := clientKey{config.ServerUrl, config.IPv6}
+	if s.cached != nil && s.cachedFor == key {
+		return *s.cached, nil
+	}
+	client, err := newClient(config, s.nic)
+	if err != nil {
+		return client, err
+	}
+	if len(client.LocalAddrs()) > 0 {
+		s.cached, s.cachedFor = &client, key
+	}
+	return client, nil
+}
+
+// reschedule makes the supervisor pick up a changed test interval
+func (s *supervisor) reschedule() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *supervisor) run(d *daemon) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var lastDial time.Time
+	failures := 0
+
+	for {
+		var waiters []chan error
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.wake:
+			if !lastDial.IsZero() {
+				timer.Reset(time.Until(lastDial.Add(d.nextWait(s.nic, failures))))
+			}
+			continue
+		case waiter := <-s.triggers:
+			waiters = append(waiters, waiter)
+		case <-timer.C:
+			if d.paused(s.nic) {
+				timer.Reset(d.nextWait(s.nic, 0))
+				continue
+			}
+		}
+		// triggers queued meanwhile are served by this very dial
+		for drained := false; !drained; {
+			select {
+			case waiter := <-s.triggers:
+				waiters = append(waiters, waiter)
+			default:
+				drained = true
+			}
+		}
+
+		err := d.dialNic(s)
+		lastDial = time.Now()
+		for _, waiter := range waiters {
+			waiter <- err
+		}
+		if err != nil {
+			failures++
+		} else {
+			failures = 0
+		}
+		timer.Stop()
+		timer.Reset(d.nextWait(s.nic, failures))
+	}
+}